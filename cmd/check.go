@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tanq16/backhub/functionality"
+)
+
+var checkMaxAge time.Duration
+var checkWarnAge time.Duration
+
+var checkCmd = &cobra.Command{
+	Use:   "check [config_file]",
+	Short: "Check that every mirror was backed up recently, for monitoring systems",
+	Long: `Reports whether every configured repository had a successful backup within
+--max-age, as a Nagios-compatible plugin. Each repository's last success is the
+newer of the one recorded in the run history of the backup directory and the
+backhub-last-success marker written into its mirror after every successful
+backup. It prints one line with perfdata and exits with the plugin state:
+
+  0  OK: every repository is fresh
+  1  WARNING: some repository is older than --warn-age
+  2  CRITICAL: some repository is older than --max-age or was never backed up
+  3  UNKNOWN: the config or run history could not be read
+
+Examples:
+  backhub check config.yaml                        # Critical after 26 hours
+  backhub check --max-age 50h --warn-age 26h config.yaml`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if checkWarnAge > checkMaxAge {
+			exitUnknown(fmt.Errorf("--warn-age %s exceeds --max-age %s", checkWarnAge, checkMaxAge))
+		}
+		cfg, err := functionality.ReadConfig(args[0])
+		if err != nil {
+			exitUnknown(err)
+		}
+		runs, err := functionality.ReadHistory(backupDir)
+		if err != nil {
+			exitUnknown(err)
+		}
+		report := functionality.CheckFreshness(cfg.Repos, runs, backupDir, time.Now(), checkWarnAge, checkMaxAge)
+		fmt.Println(report.Line())
+		os.Exit(int(report.State))
+	},
+}
+
+func init() {
+	checkCmd.Flags().DurationVar(&checkMaxAge, "max-age", 26*time.Hour, "Age of the last successful backup that is critical")
+	checkCmd.Flags().DurationVar(&checkWarnAge, "warn-age", 0, "Age of the last successful backup that warns (0 disables)")
+	addDirFlag(checkCmd)
+	rootCmd.AddCommand(checkCmd)
+}
+
+// Prints the UNKNOWN plugin line for an error and exits
+func exitUnknown(err error) {
+	fmt.Println(functionality.CheckUnknownLine(err))
+	os.Exit(int(functionality.CheckUnknown))
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tanq16/backhub/functionality"
+	"github.com/tanq16/backhub/utils"
+)
+
+var daemonSchedule string
+var daemonJitter time.Duration
+var metricsAddr string
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [config_file_or_repo]",
+	Short: "Keep running and back up repositories on a schedule",
+	Long: `Keeps BackHub running and backs up each repository when it is due. Repos
+follow their own interval or cron expression from the config file, or the default
+--schedule otherwise; repos never backed up are due right away. Due repos are
+dispatched highest priority first. A random jitter can be added to each run, runs
+never overlap, and SIGINT/SIGTERM stop the daemon, cancelling the in-flight run
+cleanly. The configuration file is re-read for every run.
+
+Examples:
+  backhub daemon --schedule "0 */6 * * *" config.yaml     # Every 6 hours
+  backhub daemon --schedule @daily --jitter 10m config.yaml
+  backhub daemon --schedule @daily --metrics-addr :9090 config.yaml`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := args[0]
+		token := os.Getenv("GH_TOKEN")
+		var schedule *utils.CronSchedule
+		if daemonSchedule != "" {
+			var err error
+			if schedule, err = utils.ParseCron(daemonSchedule); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(exitConfigError)
+			}
+		}
+		if err := utils.ConfigureDisplay(themeName, asciiOnly); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		if _, err := utils.NewRenderer(outputMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		if err := openLogFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		defer closeLogFile()
+		ctx, stop := signalContext()
+		defer stop()
+		daemon := functionality.NewDaemon(token, configPath, schedule, daemonJitter)
+		daemon.SetOutputFactory(func() functionality.ProgressSink {
+			renderer, _ := utils.NewRenderer(outputMode) // mode validated above
+			return newOutputManager(renderer)
+		})
+		daemon.SetCloneFolder(backupDir)
+		daemon.SetRetryPolicy(retries, retryDelay)
+		daemon.SetRepoTimeout(repoTimeout)
+		daemon.SetReportPath(reportJSONPath)
+		daemon.SetResultsPath(resultsPath)
+		if metricsAddr != "" || metricsPath != "" {
+			metrics := functionality.NewMetrics()
+			daemon.SetMetrics(metrics, metricsPath)
+			if metricsAddr != "" {
+				if err := serveMetrics(ctx, metricsAddr, metrics); err != nil {
+					closeLogFile()
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+					os.Exit(exitConfigError)
+				}
+			}
+		}
+		if err := daemon.Run(ctx); err != nil {
+			closeLogFile()
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonSchedule, "schedule", "", "Default cron expression for repos without their own schedule (e.g. \"0 */6 * * *\" or @daily)")
+	daemonCmd.Flags().DurationVar(&daemonJitter, "jitter", 0, "Maximum random delay added to each scheduled run")
+	daemonCmd.Flags().BoolVar(&unlimitedOutput, "debug", false, "Show unlimited console output")
+	daemonCmd.Flags().StringVar(&outputMode, "output", "", "Output mode: tty, plain or json (default: tty on terminals, plain otherwise)")
+	daemonCmd.Flags().IntVar(&retries, "retries", 2, "Retries for transient clone/fetch errors (timeouts, 5xx, resets, rate limits)")
+	daemonCmd.Flags().DurationVar(&retryDelay, "retry-delay", 2*time.Second, "Initial delay between retries, doubled per attempt with jitter")
+	daemonCmd.Flags().DurationVar(&repoTimeout, "timeout", 30*time.Minute, "Maximum time to back up a single repository (0 disables it)")
+	daemonCmd.Flags().StringVar(&reportJSONPath, "report-json", "", "Write the result of every run as JSON to this path")
+	daemonCmd.Flags().StringVar(&resultsPath, "results-file", "", "Write the results table of every run to this path, as CSV for .csv files and markdown otherwise")
+	daemonCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address at /metrics (e.g. :9090)")
+	daemonCmd.Flags().StringVar(&metricsPath, "metrics-file", "", "Write Prometheus metrics after every run to this node_exporter textfile (*.prom)")
+	addDirFlag(daemonCmd)
+	addLogFlags(daemonCmd)
+	addDisplayFlags(daemonCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// Serves metrics at /metrics until the context is cancelled; fails if the address can't be bound
+func serveMetrics(ctx context.Context, addr string, metrics *functionality.Metrics) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics listener: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	utils.PrintInfo(fmt.Sprintf("Serving metrics at http://%s/metrics", listener.Addr()))
+	return nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tanq16/backhub/functionality"
+)
+
+var digestPeriod time.Duration
+var digestDryRun bool
+
+var digestCmd = &cobra.Command{
+	Use:   "digest [config_file]",
+	Short: "Email a digest of backup health from the run history",
+	Long: `Summarizes the runs recorded in the history file of the backup root over the
+last --period: repositories backed up, failures, newly discovered repositories,
+history rewrites and storage growth. The digest is sent as an HTML and plain-text
+email with the SMTP settings from the email section of the config file. In daemon
+mode, the email schedule sends it automatically.
+
+Examples:
+  backhub digest config.yaml                     # Digest of the last 24 hours
+  backhub digest --period 168h config.yaml       # Weekly digest
+  backhub digest --dry-run config.yaml           # Print instead of sending`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := args[0]
+		cfg, err := functionality.ReadConfig(configPath)
+		if err == nil && cfg.Email == nil && !digestDryRun {
+			err = fmt.Errorf("%s has no email settings", configPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		history, err := functionality.ReadHistory(backupDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		until := time.Now()
+		digest := functionality.BuildDigest(history, until.Add(-digestPeriod), until)
+		if digestDryRun {
+			fmt.Print(digest.Text())
+			return
+		}
+		if err := cfg.Email.SendDigest(digest); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: sending digest: %s\n", err)
+			os.Exit(exitTotalFailure)
+		}
+	},
+}
+
+func init() {
+	digestCmd.Flags().DurationVar(&digestPeriod, "period", 24*time.Hour, "Time span of runs covered by the digest")
+	digestCmd.Flags().BoolVar(&digestDryRun, "dry-run", false, "Print the plain-text digest instead of sending it")
+	addDirFlag(digestCmd)
+	rootCmd.AddCommand(digestCmd)
+}
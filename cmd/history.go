@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tanq16/backhub/functionality"
+	"github.com/tanq16/backhub/utils"
+)
+
+var historyLimit int
+var historyRepo string
+var historyJSON bool
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past runs and per-repository backup history",
+	Long: `Reads the run history that every backup appends to .backhub-history.jsonl in
+the backup directory and shows the most recent runs, followed by each repository's
+last success, current failure streak and average duration with its trend against
+earlier runs.
+
+Examples:
+  backhub history                              # Last 10 runs and all repositories
+  backhub history --limit 50                   # Last 50 runs
+  backhub history --repo github.com/org/repo   # Runs and history of one repository
+  backhub history --json                       # Machine-readable output`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if historyLimit < 0 {
+			fmt.Fprintf(os.Stderr, "Error: --limit %d must not be negative\n", historyLimit)
+			os.Exit(exitConfigError)
+		}
+		if err := utils.ConfigureDisplay(themeName, asciiOnly); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		runs, err := functionality.ReadHistory(backupDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		if historyRepo != "" {
+			runs = slices.DeleteFunc(runs, func(run functionality.RunResult) bool {
+				return !slices.ContainsFunc(run.Repos, func(repo functionality.RepoResult) bool { return repo.Repo == historyRepo })
+			})
+		}
+		repos := functionality.SummarizeHistory(runs)
+		if historyRepo != "" {
+			repos = slices.DeleteFunc(repos, func(repo functionality.RepoHistory) bool { return repo.Repo != historyRepo })
+		}
+		recent := runs[max(len(runs)-historyLimit, 0):]
+		if historyJSON {
+			data, _ := json.MarshalIndent(struct {
+				Runs  []functionality.RunResult   `json:"runs"`
+				Repos []functionality.RepoHistory `json:"repos"`
+			}{recent, repos}, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+		if len(runs) == 0 {
+			utils.PrintInfo("No runs recorded yet")
+			return
+		}
+		printRunsTable(recent)
+		fmt.Println()
+		printReposTable(repos)
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 10, "Number of most recent runs shown")
+	historyCmd.Flags().StringVar(&historyRepo, "repo", "", "Only show runs and history of this repository")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Print the runs and repository history as JSON")
+	addDirFlag(historyCmd)
+	addDisplayFlags(historyCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// Prints one row per run, newest first
+func printRunsTable(runs []functionality.RunResult) {
+	table := utils.NewTable([]string{"Started", "Status", "Repos", "Succeeded", "Failed", "Skipped", "Received", "Duration"})
+	for _, run := range slices.Backward(runs) {
+		var received int64
+		for _, repo := range run.Repos {
+			received += repo.BytesReceived
+		}
+		table.Rows = append(table.Rows, []string{
+			run.StartTime.Local().Format(time.DateTime), run.Status(),
+			fmt.Sprint(len(run.Repos)), fmt.Sprint(run.Succeeded()), fmt.Sprint(run.Count(functionality.OutcomeFailed)), fmt.Sprint(run.Count(functionality.OutcomeSkipped)),
+			utils.FormatBytes(float64(received)), run.Duration.Round(time.Second).String(),
+		})
+	}
+	table.PrintTable(false)
+	fmt.Println()
+}
+
+// Prints one row per repository with its last success, failure streak and duration trend
+func printReposTable(repos []functionality.RepoHistory) {
+	table := utils.NewTable([]string{"Repository", "Last Outcome", "Last Success", "Failure Streak", "Avg Duration", "Trend"})
+	for _, repo := range repos {
+		lastSuccess := "never"
+		if !repo.LastSuccess.IsZero() {
+			lastSuccess = fmt.Sprintf("%s (%s ago)", repo.LastSuccess.Local().Format(time.DateTime), time.Since(repo.LastSuccess).Round(time.Minute))
+		}
+		average, trend := "-", "-"
+		if duration := repo.AverageDuration(); duration > 0 {
+			average = duration.Round(time.Millisecond).String()
+		}
+		if change, ok := repo.Trend(); ok {
+			trend = fmt.Sprintf("%+.0f%%", change*100)
+		}
+		table.Rows = append(table.Rows, []string{repo.Repo, string(repo.LastOutcome), lastSuccess, fmt.Sprint(repo.FailureStreak), average, trend})
+	}
+	table.PrintTable(false)
+	fmt.Println()
+}
@@ -1,15 +1,44 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/tanq16/backhub/functionality"
+	"github.com/tanq16/backhub/pkg/backhub"
+	"github.com/tanq16/backhub/utils"
 )
 
 var BackHubVersion = "dev"
 var unlimitedOutput bool
+var outputMode string
+var retries int
+var retryDelay time.Duration
+var repoTimeout time.Duration
+var reportJSONPath string
+var resultsPath string
+var metricsPath string
+var backupDir string
+var logFilePath string
+var logMaxSize int64
+var logLevel string
+var logFile *utils.LogFile
+var interactiveMode bool
+var themeName string
+var asciiOnly bool
+
+// Process exit codes
+const (
+	exitOK             = 0 // every repository succeeded
+	exitPartialFailure = 1 // some repositories failed or were skipped
+	exitTotalFailure   = 2 // no repository succeeded
+	exitConfigError    = 3 // invalid arguments or configuration
+)
 
 var rootCmd = &cobra.Command{
 	Use:     "backhub [config_file_or_repo]",
@@ -21,27 +50,191 @@ configuration file or directly specified as a command line argument.
 
 Examples:
   backhub config.yaml                   # Backup repos from config file
-  backhub github.com/username/repo      # Backup a single repository`,
+  backhub github.com/username/repo      # Backup a single repository
+
+Exit codes:
+  0  all repositories succeeded
+  1  some repositories failed or were skipped
+  2  no repository succeeded
+  3  invalid arguments or configuration`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		configPath := args[0]
 		token := os.Getenv("GH_TOKEN")
-		handler := functionality.NewHandler(token)
-		err := handler.RunBackup(configPath, unlimitedOutput)
+		cfg, err := functionality.ReadConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		if err := utils.ConfigureDisplay(themeName, asciiOnly); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		renderer, err := utils.NewRenderer(outputMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		if err := openLogFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		outputMgr := newOutputManager(renderer)
+		ctx, stop := signalContext()
+		defer stop()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if interactiveMode {
+			enableInteractive(outputMgr, cancel)
+		}
+		// ETAs and metrics build on the run history but don't need it
+		runs, _ := functionality.ReadHistory(backupDir)
+		hints := functionality.DurationHints(runs)
+		result, err := backhub.Backup(ctx, cfg.Repos,
+			backhub.WithToken(token),
+			backhub.WithDestination(backupDir),
+			backhub.WithRetries(retries, retryDelay),
+			backhub.WithTimeout(repoTimeout),
+			backhub.WithProgressSink(outputMgr),
+			backhub.WithDurationHints(hints),
+		)
+		closeLogFile()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
-			os.Exit(1)
+			os.Exit(exitConfigError)
+		}
+		if reportJSONPath != "" {
+			if err := result.WriteJSON(reportJSONPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: writing report: %s\n", err)
+			}
+		}
+		if resultsPath != "" {
+			if err := result.WriteTable(resultsPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: writing results table: %s\n", err)
+			}
+		}
+		if err := functionality.AppendHistory(backupDir, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: recording run history: %s\n", err)
 		}
+		// An interrupted run is still announced, so the signal context is not used
+		if err := functionality.NewNotifier(cfg.Notifications).Notify(context.Background(), result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: sending notifications: %s\n", err)
+		}
+		if metricsPath != "" {
+			metrics := functionality.NewMetrics()
+			metrics.Seed(runs)
+			metrics.TrackSink(outputMgr)
+			metrics.Observe(result)
+			if err := metrics.WriteTextfile(metricsPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: writing metrics: %s\n", err)
+			}
+		}
+		os.Exit(exitCode(result))
 	},
 }
 
 func init() {
 	rootCmd.Flags().BoolVar(&unlimitedOutput, "debug", false, "Show unlimited console output")
+	rootCmd.Flags().StringVar(&outputMode, "output", "", "Output mode: tty, plain or json (default: tty on terminals, plain otherwise)")
+	rootCmd.Flags().IntVar(&retries, "retries", 2, "Retries for transient clone/fetch errors (timeouts, 5xx, resets, rate limits)")
+	rootCmd.Flags().DurationVar(&retryDelay, "retry-delay", 2*time.Second, "Initial delay between retries, doubled per attempt with jitter")
+	rootCmd.Flags().DurationVar(&repoTimeout, "timeout", 30*time.Minute, "Maximum time to back up a single repository (0 disables it)")
+	rootCmd.Flags().StringVar(&reportJSONPath, "report-json", "", "Write the run result as JSON to this path")
+	rootCmd.Flags().StringVar(&resultsPath, "results-file", "", "Write the results table to this path, as CSV for .csv files and markdown otherwise")
+	rootCmd.Flags().StringVar(&metricsPath, "metrics-file", "", "Write Prometheus metrics to this node_exporter textfile (*.prom) after the run")
+	rootCmd.Flags().BoolVar(&interactiveMode, "interactive", false, "Enable keyboard controls in the tty display: up/down select, d details, p pause, q stop")
+	addDirFlag(rootCmd)
+	addLogFlags(rootCmd)
+	addDisplayFlags(rootCmd)
+}
+
+// Adds the flag selecting the backup root to a command
+func addDirFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&backupDir, "dir", ".", "Backup root holding the mirrors and the run history")
+}
+
+// Adds the theme and symbol set flags to a command
+func addDisplayFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&themeName, "theme", utils.ThemeDefault, "Display theme: default, high-contrast or monochrome (NO_COLOR forces monochrome)")
+	cmd.Flags().BoolVar(&asciiOnly, "ascii", false, "Use ASCII symbols and table borders only (implied by TERM=dumb)")
+}
+
+// Lets the keyboard control the display; quitting cancels the run like SIGINT
+func enableInteractive(outputMgr *utils.Manager, cancel context.CancelFunc) {
+	mode := outputMode
+	if mode == "" {
+		mode = utils.DetectOutputMode()
+	}
+	if mode != utils.OutputTTY || unlimitedOutput {
+		fmt.Fprintln(os.Stderr, "Warning: --interactive needs the tty output mode without --debug, ignoring it")
+		return
+	}
+	outputMgr.SetInteractive(os.Stdin, cancel)
+}
+
+// Adds the log file flags to a command
+func addLogFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&logFilePath, "log-file", "", "Write every stream line, status change and error to this file")
+	cmd.Flags().Int64Var(&logMaxSize, "log-max-size", 10, "Rotate the log file after this many MiB, keeping 3 old files (0 disables rotation)")
+	cmd.Flags().StringVar(&logLevel, "log-level", "debug", "Minimum level written to the log file: debug, info, warn or error")
+}
+
+// Opens the log file requested by the flags, if any
+func openLogFile() error {
+	if logFilePath == "" {
+		return nil
+	}
+	level, err := utils.ParseLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	logFile, err = utils.OpenLogFile(logFilePath, logMaxSize<<20, level)
+	return err
+}
+
+func closeLogFile() {
+	if logFile != nil {
+		logFile.Close()
+	}
+}
+
+// Creates an output manager drawing with the given renderer
+func newOutputManager(renderer utils.Renderer) *utils.Manager {
+	outputMgr := utils.NewManager(15)
+	outputMgr.SetUnlimitedOutput(unlimitedOutput)
+	outputMgr.SetRenderer(renderer)
+	if logFile != nil {
+		outputMgr.SetLogFile(logFile)
+	}
+	return outputMgr
+}
+
+// Maps a run result to the process exit code
+func exitCode(result *backhub.Result) int {
+	succeeded := result.Succeeded()
+	switch {
+	case succeeded == len(result.Repos):
+		return exitOK
+	case succeeded == 0:
+		return exitTotalFailure
+	default:
+		return exitPartialFailure
+	}
+}
+
+// Returns a context cancelled on SIGINT/SIGTERM; a second signal terminates immediately
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop() // restore default signal handling
+	}()
+	return ctx, stop
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitConfigError)
 	}
 }
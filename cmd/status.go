@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tanq16/backhub/functionality"
+	"github.com/tanq16/backhub/utils"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [config_file_or_repo]",
+	Short: "Compare local mirrors with their remotes without fetching",
+	Long: `Lists the refs of each remote (like git ls-remote) and compares them with
+the refs of the local mirror without downloading any objects. Each repository
+is reported as up to date, behind, ahead or rewritten, unreachable, corrupt (the
+local mirror cannot be read), or not mirrored yet.
+
+Exit codes:
+  0  every mirror is up to date
+  1  some mirrors drifted, are missing, corrupt or unreachable, or the check was interrupted
+  2  no remote could be reached
+  3  invalid arguments or configuration
+
+Examples:
+  backhub status config.yaml                 # Check repos from config file
+  backhub status github.com/username/repo    # Check a single repository`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := args[0]
+		token := os.Getenv("GH_TOKEN")
+		if err := utils.ConfigureDisplay(themeName, asciiOnly); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		renderer, err := utils.NewRenderer(outputMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		handler := functionality.NewHandler(token)
+		handler.SetProgressSink(newOutputManager(renderer))
+		handler.SetCloneFolder(backupDir)
+		ctx, stop := signalContext()
+		defer stop()
+		result, err := handler.RunStatus(ctx, configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(exitConfigError)
+		}
+		os.Exit(statusExitCode(result))
+	},
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&unlimitedOutput, "debug", false, "Show unlimited console output")
+	statusCmd.Flags().StringVar(&outputMode, "output", "", "Output mode: tty, plain or json (default: tty on terminals, plain otherwise)")
+	addDirFlag(statusCmd)
+	addDisplayFlags(statusCmd)
+	rootCmd.AddCommand(statusCmd)
+}
+
+// Maps a status check to the process exit code, like exitCode does for backups
+func statusExitCode(result *functionality.StatusResult) int {
+	switch {
+	case result.UpToDate() == result.Total:
+		return exitOK
+	case result.Unreachable() == result.Total:
+		return exitTotalFailure
+	default:
+		return exitPartialFailure
+	}
+}
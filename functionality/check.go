@@ -0,0 +1,118 @@
+package functionality
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Monitoring plugin states, which are also the exit codes of Nagios-style checks
+type CheckState int
+
+const (
+	CheckOK       CheckState = 0
+	CheckWarning  CheckState = 1
+	CheckCritical CheckState = 2
+	CheckUnknown  CheckState = 3
+)
+
+func (s CheckState) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// Age of a repository's latest successful backup
+type RepoFreshness struct {
+	Repo        string
+	LastSuccess time.Time // zero if never backed up
+	Age         time.Duration
+	FromMirror  bool // taken from the success marker in the mirror, which is newer than the run history
+	State       CheckState
+}
+
+// Freshness of all configured repositories against warning and critical ages
+type FreshnessReport struct {
+	Repos   []RepoFreshness
+	WarnAge time.Duration // 0 disables the warning threshold
+	MaxAge  time.Duration
+	State   CheckState
+	Checked time.Time
+}
+
+// Checks when each repository was last backed up, from the run history or the
+// success marker of its mirror in dir, whichever is newer
+func CheckFreshness(repos []RepoEntry, runs []RunResult, dir string, now time.Time, warnAge, maxAge time.Duration) *FreshnessReport {
+	report := &FreshnessReport{WarnAge: warnAge, MaxAge: maxAge, State: CheckOK, Checked: now}
+	successes := LastSuccesses(runs)
+	for _, repo := range repos {
+		freshness := RepoFreshness{Repo: repo.URL, LastSuccess: successes[repo.URL]}
+		if marker := readSuccessMarker(mirrorPath(dir, repo.URL)); marker.After(freshness.LastSuccess) {
+			freshness.LastSuccess = marker
+			freshness.FromMirror = true
+		}
+		switch {
+		case freshness.LastSuccess.IsZero():
+			freshness.State = CheckCritical
+		default:
+			freshness.Age = now.Sub(freshness.LastSuccess)
+			if freshness.Age > maxAge {
+				freshness.State = CheckCritical
+			} else if warnAge > 0 && freshness.Age > warnAge {
+				freshness.State = CheckWarning
+			}
+		}
+		report.State = max(report.State, freshness.State)
+		report.Repos = append(report.Repos, freshness)
+	}
+	return report
+}
+
+// Formats the report as one plugin output line with perfdata, such as
+// "BACKHUB CRITICAL - 1 of 3 repositories stale: github.com/org/a (never) | ..."
+func (r *FreshnessReport) Line() string {
+	var stale []string
+	var never int
+	var oldest time.Duration
+	for _, repo := range r.Repos {
+		if repo.LastSuccess.IsZero() {
+			never++
+		}
+		oldest = max(oldest, repo.Age)
+		switch {
+		case repo.State == CheckOK:
+			continue
+		case repo.LastSuccess.IsZero():
+			stale = append(stale, fmt.Sprintf("%s (never)", repo.Repo))
+		default:
+			stale = append(stale, fmt.Sprintf("%s (%s)", repo.Repo, repo.Age.Round(time.Minute)))
+		}
+	}
+	summary := fmt.Sprintf("all %d repositories backed up within %s", len(r.Repos), r.MaxAge)
+	if len(stale) > 0 {
+		summary = fmt.Sprintf("%d of %d repositories stale: %s", len(stale), len(r.Repos), strings.Join(stale, ", "))
+	}
+	warn := ""
+	if r.WarnAge > 0 {
+		warn = fmt.Sprintf("%.0f", r.WarnAge.Seconds())
+	}
+	// A repository that was never backed up has no finite age, so the oldest is undetermined
+	oldestAge := fmt.Sprintf("%.0fs", oldest.Seconds())
+	if never > 0 {
+		oldestAge = "U"
+	}
+	perfdata := fmt.Sprintf("repos=%d stale=%d;;;0;%d never=%d;;;0;%d oldest_age=%s;%s;%.0f;0",
+		len(r.Repos), len(stale), len(r.Repos), never, len(r.Repos), oldestAge, warn, r.MaxAge.Seconds())
+	return fmt.Sprintf("BACKHUB %s - %s | %s", r.State, summary, perfdata)
+}
+
+// Formats an error that prevented the check as an UNKNOWN plugin line
+func CheckUnknownLine(err error) string {
+	return fmt.Sprintf("BACKHUB %s - %s", CheckUnknown, err)
+}
@@ -0,0 +1,64 @@
+package functionality
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	now := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	// d has no recorded run, but its mirror marks a backup an hour ago; e's mirror was
+	// just written by a failed fetch, which leaves no marker
+	for _, repo := range []string{"github.com/org/d", "github.com/org/e"} {
+		mirror := mirrorPath(dir, repo)
+		if err := os.MkdirAll(mirror, 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(mirror, "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	}
+	if err := writeSuccessMarker(mirrorPath(dir, "github.com/org/d"), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []RunResult{
+		{StartTime: now.Add(-30 * time.Hour), Repos: []RepoResult{
+			{Repo: "github.com/org/a", Outcome: OutcomeUpdated},
+			{Repo: "github.com/org/b", Outcome: OutcomeUpdated},
+		}},
+		{StartTime: now.Add(-2 * time.Hour), Repos: []RepoResult{
+			{Repo: "github.com/org/a", Outcome: OutcomeUnchanged},
+			{Repo: "github.com/org/b", Outcome: OutcomeFailed},
+		}},
+	}
+	repos := []RepoEntry{{URL: "github.com/org/a"}, {URL: "github.com/org/b"}, {URL: "github.com/org/c"}, {URL: "github.com/org/d"}, {URL: "github.com/org/e"}}
+
+	report := CheckFreshness(repos, runs, dir, now, 24*time.Hour, 48*time.Hour)
+	want := []CheckState{CheckOK, CheckWarning, CheckCritical, CheckOK, CheckCritical}
+	for i, repo := range report.Repos {
+		if repo.State != want[i] {
+			t.Errorf("%s is %s, want %s", repo.Repo, repo.State, want[i])
+		}
+	}
+	if !report.Repos[3].FromMirror {
+		t.Error("d should use its mirror's success marker")
+	}
+	if report.State != CheckCritical {
+		t.Errorf("report is %s, want CRITICAL", report.State)
+	}
+	line := report.Line()
+	for _, part := range []string{"BACKHUB CRITICAL - 3 of 5 repositories stale", "github.com/org/b (30h0m0s)", "github.com/org/c (never)", "github.com/org/e (never)", "never=2;", "oldest_age=U;86400;172800;0"} {
+		if !strings.Contains(line, part) {
+			t.Errorf("line lacks %q: %s", part, line)
+		}
+	}
+
+	report = CheckFreshness(repos[:2], runs, dir, now, 0, 32*time.Hour)
+	if report.State != CheckOK || !strings.HasPrefix(report.Line(), "BACKHUB OK - all 2 repositories backed up within 32h0m0s |") ||
+		!strings.HasSuffix(report.Line(), "oldest_age=108000s;;115200;0") {
+		t.Errorf("fresh report: %s", report.Line())
+	}
+}
@@ -0,0 +1,333 @@
+package functionality
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// Delay before a repository whose backup failed becomes due again
+const failedRepoRetryDelay = 15 * time.Minute
+
+// Keeps the process alive and dispatches repositories whose schedule is due
+type Daemon struct {
+	token          string
+	configPath     string
+	cloneFolder    string                 // Backup root holding the mirrors and the run history
+	config         atomic.Pointer[Config] // Last valid config, re-read before every run
+	schedule       *utils.CronSchedule    // Default schedule for repos without their own
+	jitter         time.Duration
+	newOutput      func() ProgressSink // Creates a fresh output manager for every run
+	retries        int
+	retryBaseDelay time.Duration
+	repoTimeout    time.Duration
+	reportPath     string
+	resultsPath    string
+	metrics        *Metrics
+	metricsPath    string
+	startTime      time.Time
+	lastSuccess    map[string]time.Time
+	lastAttempt    map[string]time.Time
+}
+
+func NewDaemon(token, configPath string, schedule *utils.CronSchedule, jitter time.Duration) *Daemon {
+	return &Daemon{
+		token:          token,
+		configPath:     configPath,
+		cloneFolder:    ".",
+		schedule:       schedule,
+		jitter:         jitter,
+		newOutput:      func() ProgressSink { return utils.NewManager(15) },
+		lastSuccess:    make(map[string]time.Time),
+		lastAttempt:    make(map[string]time.Time),
+		retries:        2,
+		retryBaseDelay: 2 * time.Second,
+		repoTimeout:    30 * time.Minute,
+	}
+}
+
+// Sets the backup root in which mirrors and the run history are kept
+func (d *Daemon) SetCloneFolder(folder string) {
+	d.cloneFolder = folder
+}
+
+// Sets a path to which the JSON result of every run is written
+func (d *Daemon) SetReportPath(path string) {
+	d.reportPath = path
+}
+
+// Sets a path to which the results table of every run is written
+func (d *Daemon) SetResultsPath(path string) {
+	d.resultsPath = path
+}
+
+// Sets the metrics fed by every run and a path to which they are written as a
+// node_exporter textfile after each run ("" for none)
+func (d *Daemon) SetMetrics(metrics *Metrics, textfilePath string) {
+	d.metrics = metrics
+	d.metricsPath = textfilePath
+}
+
+// Sets the per-repository timeout applied to the handler of every run
+func (d *Daemon) SetRepoTimeout(timeout time.Duration) {
+	d.repoTimeout = timeout
+}
+
+// Sets the retry policy applied to the handler of every run
+func (d *Daemon) SetRetryPolicy(retries int, baseDelay time.Duration) {
+	d.retries = retries
+	d.retryBaseDelay = baseDelay
+}
+
+// Sets how the output manager of every run is created
+func (d *Daemon) SetOutputFactory(factory func() ProgressSink) {
+	d.newOutput = factory
+}
+
+// Returns the duration of each repository's latest successful backup from the run history
+func (d *Daemon) durationHints() map[string]time.Duration {
+	runs, err := ReadHistory(d.cloneFolder)
+	if err != nil {
+		return nil // ETAs are optional
+	}
+	return DurationHints(runs)
+}
+
+// Sends the run summary to the notification targets of the config
+func (d *Daemon) notify(result *RunResult) {
+	// Shutdown cancels the run but should still announce it
+	if err := NewNotifier(d.config.Load().Notifications).Notify(context.Background(), result); err != nil {
+		utils.PrintError(fmt.Sprintf("Failed to send notifications: %s", err))
+	}
+}
+
+// Emails the digest on the schedule of the email settings until the context is
+// cancelled; the settings of the latest config are used for every wait and every send
+func (d *Daemon) runDigests(ctx context.Context) {
+	var from time.Time // start of the runs no digest has covered yet
+	for {
+		var schedule *utils.CronSchedule
+		next := time.Now().Add(time.Hour) // check again later for newly added settings
+		if email := d.config.Load().Email; email != nil && email.Schedule != "" {
+			schedule, _ = utils.ParseCron(email.Schedule) // validated when reading
+			next = schedule.Next(time.Now())
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if schedule == nil {
+			continue
+		}
+		// The digest covers the runs since the previous one was sent, or since the
+		// previous schedule match for the first, such as Friday to Monday for weekdays
+		if from.IsZero() {
+			from = schedule.Prev(next)
+		}
+		email := d.config.Load().Email
+		if email == nil {
+			continue // removed from the config while waiting
+		}
+		if err := email.SendHistoryDigest(d.cloneFolder, from, next); err != nil {
+			utils.PrintError(fmt.Sprintf("Failed to send digest: %s", err))
+			continue // the next digest covers these runs as well
+		}
+		from = next
+		utils.PrintInfo("Digest email sent")
+	}
+}
+
+// Schedules runs until the context is cancelled, which also cancels an in-flight run
+func (d *Daemon) Run(ctx context.Context) error {
+	d.startTime = time.Now()
+	utils.PrintInfo(fmt.Sprintf("BackHub daemon started for '%s'", d.configPath))
+	// Repos backed up before a restart are not due again until their schedule says so
+	if runs, err := ReadHistory(d.cloneFolder); err != nil {
+		utils.PrintWarning(fmt.Sprintf("Ignoring run history: %s", err))
+	} else {
+		maps.Copy(d.lastSuccess, LastSuccesses(runs))
+		if d.metrics != nil {
+			d.metrics.Seed(runs)
+		}
+	}
+	cfg, err := d.loadConfig()
+	if err != nil {
+		return err
+	}
+	d.config.Store(cfg)
+	go d.runDigests(ctx)
+	for {
+		next := d.nextWake(cfg.Repos)
+		if d.jitter > 0 {
+			next = next.Add(rand.N(d.jitter))
+		}
+		utils.PrintInfo(fmt.Sprintf("Next run scheduled at %s", next.Format(time.DateTime)))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			utils.PrintInfo("BackHub daemon stopped")
+			return nil
+		case <-timer.C:
+		}
+
+		// Re-read the config so edits made while sleeping are honoured
+		cfg = d.reloadConfig(cfg)
+		d.config.Store(cfg)
+		due := d.dueRepos(cfg.Repos, time.Now())
+		if len(due) == 0 {
+			continue
+		}
+		// Runs are synchronous so they never overlap; a shutdown request cancels
+		// the in-flight run, which still prints its summary before returning
+		d.runOnce(ctx, due)
+		if ctx.Err() != nil {
+			utils.PrintInfo("BackHub daemon stopped")
+			return nil
+		}
+	}
+}
+
+// Reads the config and checks that every repo has a schedule to follow
+func (d *Daemon) loadConfig() (*Config, error) {
+	cfg, err := ReadConfig(d.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if d.schedule == nil {
+		for _, repo := range cfg.Repos {
+			if repo.Interval == 0 && repo.Cron == "" {
+				return nil, fmt.Errorf("%s has no interval or cron and no default schedule is set", repo.URL)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Re-reads the config, keeping the previous one if it is no longer valid
+func (d *Daemon) reloadConfig(previous *Config) *Config {
+	cfg, err := d.loadConfig()
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Keeping the previous configuration: %s", err))
+		return previous
+	}
+	return cfg
+}
+
+// Computes when a repository is next due based on its last successful backup
+func (d *Daemon) nextDue(repo RepoEntry) time.Time {
+	last, exists := d.lastSuccess[repo.URL]
+	var next time.Time
+	switch {
+	case !exists: // never backed up, so due right away
+		next = d.startTime
+	case repo.Cron != "":
+		schedule, _ := utils.ParseCron(repo.Cron) // validated when loading the config
+		next = schedule.Next(last)
+	case repo.Interval > 0:
+		next = last.Add(repo.Interval)
+	default:
+		next = d.schedule.Next(last)
+	}
+	// Failed repos are retried after a delay instead of immediately
+	if attempt, exists := d.lastAttempt[repo.URL]; exists && attempt.After(last) && next.Before(attempt.Add(failedRepoRetryDelay)) {
+		next = attempt.Add(failedRepoRetryDelay)
+	}
+	return next
+}
+
+// Returns the earliest time any repository becomes due
+func (d *Daemon) nextWake(repos []RepoEntry) time.Time {
+	var earliest time.Time
+	for _, repo := range repos {
+		next := d.nextDue(repo)
+		if next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || next.Before(earliest) {
+			earliest = next
+		}
+	}
+	if earliest.IsZero() { // nothing scheduled, check the config again later
+		earliest = time.Now().Add(time.Hour)
+	}
+	return earliest
+}
+
+// Selects repositories that are due; ExecuteBackup dispatches them by priority
+func (d *Daemon) dueRepos(repos []RepoEntry, now time.Time) []RepoEntry {
+	var due []RepoEntry
+	for _, repo := range repos {
+		next := d.nextDue(repo)
+		if !next.IsZero() && !next.After(now) {
+			due = append(due, repo)
+		}
+	}
+	return due
+}
+
+// Runs a single backup of the due repositories with a fresh handler and output manager
+func (d *Daemon) runOnce(ctx context.Context, due []RepoEntry) {
+	startTime := time.Now()
+	handler := NewHandler(d.token)
+	sink := d.newOutput()
+	if d.metrics != nil {
+		d.metrics.TrackSink(sink)
+	}
+	handler.SetProgressSink(sink)
+	handler.SetCloneFolder(d.cloneFolder)
+	handler.SetRetryPolicy(d.retries, d.retryBaseDelay)
+	handler.SetRepoTimeout(d.repoTimeout)
+	handler.SetDurationHints(d.durationHints())
+	result, err := handler.RunBackupRepos(ctx, due)
+	duration := time.Since(startTime).Round(time.Millisecond)
+	for _, repo := range due {
+		d.lastAttempt[repo.URL] = startTime
+	}
+	if err != nil {
+		utils.PrintError(fmt.Sprintf("Run failed after %s: %s", duration, err))
+		return
+	}
+	for _, repo := range result.Repos {
+		switch repo.Outcome {
+		case OutcomeCloned, OutcomeUpdated, OutcomeUnchanged:
+			d.lastSuccess[repo.Repo] = startTime
+		}
+	}
+	if d.reportPath != "" {
+		if err := result.WriteJSON(d.reportPath); err != nil {
+			utils.PrintError(fmt.Sprintf("Failed to write report: %s", err))
+		}
+	}
+	if d.resultsPath != "" {
+		if err := result.WriteTable(d.resultsPath); err != nil {
+			utils.PrintError(fmt.Sprintf("Failed to write results table: %s", err))
+		}
+	}
+	if err := AppendHistory(d.cloneFolder, result); err != nil {
+		utils.PrintError(fmt.Sprintf("Failed to record run history: %s", err))
+	}
+	d.notify(result)
+	if d.metrics != nil {
+		d.metrics.Observe(result)
+		if d.metricsPath != "" {
+			if err := d.metrics.WriteTextfile(d.metricsPath); err != nil {
+				utils.PrintError(fmt.Sprintf("Failed to write metrics: %s", err))
+			}
+		}
+	}
+
+	if failed := result.Count(OutcomeFailed); failed > 0 {
+		utils.PrintWarning(fmt.Sprintf("Run finished in %s: %d succeeded, %d failed", duration, result.Succeeded(), failed))
+	} else {
+		utils.PrintInfo(fmt.Sprintf("Run backed up %d repositories in %s", result.Succeeded(), duration))
+	}
+}
@@ -0,0 +1,87 @@
+package functionality
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+func TestReloadConfigKeepsLastGoodConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("repos:\n  - url: github.com/org/a\n    interval: 1h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	daemon := NewDaemon("", path, nil, 0)
+	cfg, err := daemon.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An edit that leaves a repo without any schedule is rejected
+	os.WriteFile(path, []byte("repos:\n  - github.com/org/a\n  - github.com/org/b\n"), 0644)
+	if got := daemon.reloadConfig(cfg); got != cfg {
+		t.Errorf("reload of an invalid config gave %v, want the previous %v", got.Repos, cfg.Repos)
+	}
+
+	os.WriteFile(path, []byte("repos:\n  - url: github.com/org/b\n    cron: \"@daily\"\n"), 0644)
+	if got := daemon.reloadConfig(cfg); len(got.Repos) != 1 || got.Repos[0].URL != "github.com/org/b" {
+		t.Errorf("reload of a valid config gave %v", got.Repos)
+	}
+}
+
+func TestNewDaemonDefaultOutput(t *testing.T) {
+	daemon := NewDaemon("", "config.yaml", nil, 0)
+	if _, ok := daemon.newOutput().(*utils.Manager); !ok {
+		t.Error("a daemon without an output factory has no output manager for its runs")
+	}
+}
+
+func TestNextDueAndDueRepos(t *testing.T) {
+	start := time.Date(2025, 3, 5, 10, 30, 0, 0, time.UTC)
+	schedule, _ := utils.ParseCron("0 */6 * * *")
+	daemon := NewDaemon("", "config.yaml", schedule, 0)
+	daemon.startTime = start
+	hourly := RepoEntry{URL: "github.com/org/hourly", Interval: time.Hour}
+	cron := RepoEntry{URL: "github.com/org/cron", Cron: "15 * * * *"}
+	fallback := RepoEntry{URL: "github.com/org/default"}
+	failing := RepoEntry{URL: "github.com/org/failing", Interval: time.Hour}
+	weekly := RepoEntry{URL: "github.com/org/weekly", Interval: 168 * time.Hour}
+	newFailing := RepoEntry{URL: "github.com/org/new-failing", Interval: 168 * time.Hour}
+	daemon.lastSuccess[hourly.URL] = start.Add(-2 * time.Hour)
+	daemon.lastSuccess[failing.URL] = start.Add(-2 * time.Hour)
+	daemon.lastSuccess[cron.URL] = start
+	daemon.lastSuccess[fallback.URL] = start
+	daemon.lastAttempt[newFailing.URL] = start.Add(-5 * time.Minute)
+	daemon.lastAttempt[failing.URL] = start.Add(-5 * time.Minute)
+
+	tests := []struct {
+		repo RepoEntry
+		want time.Time
+	}{
+		{hourly, start.Add(-time.Hour)},                          // overdue since its last success
+		{cron, time.Date(2025, 3, 5, 11, 15, 0, 0, time.UTC)},    // own schedule
+		{fallback, time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)}, // daemon-wide schedule
+		{weekly, start}, // never backed up, so due right away
+		{newFailing, start.Add(failedRepoRetryDelay - 5*time.Minute)},
+		{failing, start.Add(failedRepoRetryDelay - 5*time.Minute)},
+	}
+	for _, test := range tests {
+		if got := daemon.nextDue(test.repo); !got.Equal(test.want) {
+			t.Errorf("%s is due at %s, want %s", test.repo.URL, got, test.want)
+		}
+	}
+
+	repos := []RepoEntry{hourly, cron, fallback, failing, weekly, newFailing}
+	if due := daemon.dueRepos(repos, start); len(due) != 2 || due[0].URL != hourly.URL || due[1].URL != weekly.URL {
+		t.Errorf("due at start: %v, want the hourly and the new weekly repo", due)
+	}
+	if due := daemon.dueRepos(repos, start.Add(time.Hour)); len(due) != 5 {
+		t.Errorf("due an hour later: %v, want all but the default schedule", due)
+	}
+	if wake := daemon.nextWake(repos); !wake.Equal(start.Add(-time.Hour)) {
+		t.Errorf("next wake %s, want the overdue repo", wake)
+	}
+}
@@ -0,0 +1,181 @@
+package functionality
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// Failed repository in a digest, with its latest error
+type DigestFailure struct {
+	Repo     string
+	Category ErrorCategory
+	Error    string
+	Count    int // failed attempts in the period
+}
+
+// Backup health over a period, built from the run history
+type Digest struct {
+	Since         time.Time
+	Until         time.Time
+	Runs          int
+	BackedUp      []string        // repositories with a successful backup in the period
+	Failures      []DigestFailure // repositories whose latest attempt in the period failed
+	NewRepos      []string        // repositories first cloned in the period
+	Rewrites      []string        // repositories whose history was rewritten in the period
+	StorageBefore int64           // total mirror size at the start of the period
+	StorageAfter  int64           // total mirror size at the end of the period
+}
+
+// Summarizes the runs that started within [since, until)
+func BuildDigest(history []RunResult, since, until time.Time) *Digest {
+	digest := &Digest{Since: since, Until: until}
+	backedUp := make(map[string]bool)
+	failures := make(map[string]*DigestFailure)
+	rewrites := make(map[string]bool)
+	sizeBefore := make(map[string]int64)
+	sizeAfter := make(map[string]int64)
+	for _, run := range history {
+		if !run.StartTime.Before(until) {
+			continue
+		}
+		inPeriod := !run.StartTime.Before(since)
+		if inPeriod {
+			digest.Runs++
+		}
+		for _, repo := range run.Repos {
+			succeeded := repo.Outcome == OutcomeCloned || repo.Outcome == OutcomeUpdated || repo.Outcome == OutcomeUnchanged
+			if succeeded {
+				sizeAfter[repo.Repo] = repo.MirrorSize
+				if !inPeriod {
+					sizeBefore[repo.Repo] = repo.MirrorSize
+				}
+			}
+			if !inPeriod {
+				continue
+			}
+			switch {
+			case succeeded:
+				backedUp[repo.Repo] = true
+				delete(failures, repo.Repo) // recovered since
+			case repo.Outcome == OutcomeFailed:
+				failure, exists := failures[repo.Repo]
+				if !exists {
+					failure = &DigestFailure{Repo: repo.Repo}
+					failures[repo.Repo] = failure
+				}
+				failure.Category, failure.Error = repo.ErrorCategory, repo.Error
+				failure.Count++
+			}
+			if repo.Outcome == OutcomeCloned && !slices.Contains(digest.NewRepos, repo.Repo) {
+				digest.NewRepos = append(digest.NewRepos, repo.Repo)
+			}
+			if repo.RefsRewritten > 0 {
+				rewrites[repo.Repo] = true
+			}
+		}
+	}
+	for repo := range backedUp {
+		digest.BackedUp = append(digest.BackedUp, repo)
+	}
+	for repo := range rewrites {
+		digest.Rewrites = append(digest.Rewrites, repo)
+	}
+	for _, failure := range failures {
+		digest.Failures = append(digest.Failures, *failure)
+	}
+	for _, size := range sizeBefore {
+		digest.StorageBefore += size
+	}
+	for _, size := range sizeAfter {
+		digest.StorageAfter += size
+	}
+	slices.Sort(digest.BackedUp)
+	slices.Sort(digest.Rewrites)
+	slices.Sort(digest.NewRepos)
+	slices.SortFunc(digest.Failures, func(a, b DigestFailure) int { return strings.Compare(a.Repo, b.Repo) })
+	return digest
+}
+
+// Returns the subject line of the digest email
+func (d *Digest) Subject() string {
+	status := "all healthy"
+	if len(d.Failures) > 0 {
+		status = fmt.Sprintf("%d failing", len(d.Failures))
+	}
+	return fmt.Sprintf("BackHub digest %s: %d repositories backed up, %s", d.Until.Format(time.DateOnly), len(d.BackedUp), status)
+}
+
+// Describes the change in total mirror size, like "1.20 GiB (+30.00 MiB)"
+func (d *Digest) StorageGrowth() string {
+	growth := d.StorageAfter - d.StorageBefore
+	sign := "+"
+	if growth < 0 {
+		sign, growth = "-", -growth
+	}
+	return fmt.Sprintf("%s (%s%s)", utils.FormatBytes(float64(d.StorageAfter)), sign, utils.FormatBytes(float64(growth)))
+}
+
+// Renders the digest as plain text
+func (d *Digest) Text() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "BackHub digest for %s to %s\n\n", d.Since.Format(time.DateTime), d.Until.Format(time.DateTime))
+	fmt.Fprintf(&text, "Runs: %d\nRepositories backed up: %d\nFailing repositories: %d\nStorage: %s\n",
+		d.Runs, len(d.BackedUp), len(d.Failures), d.StorageGrowth())
+	if len(d.Failures) > 0 {
+		text.WriteString("\nFailures:\n")
+		for _, failure := range d.Failures {
+			fmt.Fprintf(&text, "- %s (%s, failed %dx): %s\n", failure.Repo, failure.Category, failure.Count, failure.Error)
+		}
+	}
+	section := func(title string, repos []string) {
+		if len(repos) == 0 {
+			return
+		}
+		fmt.Fprintf(&text, "\n%s:\n", title)
+		for _, repo := range repos {
+			fmt.Fprintf(&text, "- %s\n", repo)
+		}
+	}
+	section("Newly discovered repositories", d.NewRepos)
+	section("History rewrites", d.Rewrites)
+	section("Backed up", d.BackedUp)
+	return text.String()
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h2>BackHub digest</h2>
+<p>{{.Since.Format "2006-01-02 15:04"}} to {{.Until.Format "2006-01-02 15:04"}}</p>
+<table cellpadding="4">
+<tr><td>Runs</td><td>{{.Runs}}</td></tr>
+<tr><td>Repositories backed up</td><td>{{len .BackedUp}}</td></tr>
+<tr><td>Failing repositories</td><td{{if .Failures}} style="color: #c00"{{end}}>{{len .Failures}}</td></tr>
+<tr><td>Storage</td><td>{{.StorageGrowth}}</td></tr>
+</table>
+{{if .Failures}}<h3>Failures</h3>
+<table border="1" cellpadding="4" style="border-collapse: collapse">
+<tr><th>Repository</th><th>Category</th><th>Attempts</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{.Repo}}</td><td>{{.Category}}</td><td>{{.Count}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>{{end}}
+{{if .NewRepos}}<h3>Newly discovered repositories</h3>
+<ul>{{range .NewRepos}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Rewrites}}<h3>History rewrites</h3>
+<ul>{{range .Rewrites}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .BackedUp}}<h3>Backed up</h3>
+<ul>{{range .BackedUp}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</body></html>
+`))
+
+// Renders the digest as an HTML document
+func (d *Digest) HTML() (string, error) {
+	var out strings.Builder
+	if err := digestTemplate.Execute(&out, d); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,60 @@
+package functionality
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Three daily runs; the digest covers the last two days
+func digestHistory() []RunResult {
+	day := time.Date(2025, 3, 1, 3, 0, 0, 0, time.UTC)
+	return []RunResult{
+		{StartTime: day, Repos: []RepoResult{
+			{Repo: "github.com/org/a", Outcome: OutcomeCloned, MirrorSize: 1 << 20},
+			{Repo: "github.com/org/b", Outcome: OutcomeCloned, MirrorSize: 1 << 20},
+		}},
+		{StartTime: day.Add(24 * time.Hour), Repos: []RepoResult{
+			{Repo: "github.com/org/a", Outcome: OutcomeUpdated, MirrorSize: 2 << 20, RefsRewritten: 2},
+			{Repo: "github.com/org/b", Outcome: OutcomeFailed, ErrorCategory: ErrorNetwork, Error: "connection reset"},
+			{Repo: "github.com/org/c", Outcome: OutcomeCloned, MirrorSize: 1 << 20},
+		}},
+		{StartTime: day.Add(48 * time.Hour), Repos: []RepoResult{
+			{Repo: "github.com/org/a", Outcome: OutcomeUnchanged, MirrorSize: 2 << 20},
+			{Repo: "github.com/org/b", Outcome: OutcomeFailed, ErrorCategory: ErrorAuth, Error: "authentication required"},
+			{Repo: "github.com/org/c", Outcome: OutcomeUnchanged, MirrorSize: 1 << 20},
+		}},
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	since := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	digest := BuildDigest(digestHistory(), since, since.Add(48*time.Hour))
+	if digest.Runs != 2 {
+		t.Errorf("counted %d runs, want 2", digest.Runs)
+	}
+	if !slices.Equal(digest.BackedUp, []string{"github.com/org/a", "github.com/org/c"}) {
+		t.Errorf("backed up %v", digest.BackedUp)
+	}
+	if len(digest.Failures) != 1 || digest.Failures[0].Count != 2 || digest.Failures[0].Category != ErrorAuth {
+		t.Errorf("failures %+v, want b failing twice, lastly with auth", digest.Failures)
+	}
+	if !slices.Equal(digest.NewRepos, []string{"github.com/org/c"}) || !slices.Equal(digest.Rewrites, []string{"github.com/org/a"}) {
+		t.Errorf("new repos %v, rewrites %v", digest.NewRepos, digest.Rewrites)
+	}
+	if growth := digest.StorageGrowth(); growth != "4.00 MiB (+2.00 MiB)" {
+		t.Errorf("storage growth = %q", growth)
+	}
+
+	text := digest.Text()
+	html, err := digest.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"github.com/org/b", "authentication required", "github.com/org/c"} {
+		if !strings.Contains(text, want) || !strings.Contains(html, want) {
+			t.Errorf("digest lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package functionality
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// SMTP settings from the email section of the config file
+type EmailConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"` // defaults to 587
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"` // SMTP_PASSWORD overrides it
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	StartTLS *bool    `yaml:"starttls"` // defaults to true; disable only for local relays
+	Schedule string   `yaml:"schedule"` // daemon mode: cron expression like @daily or @weekly
+
+	tlsConfig *tls.Config // replaces the default STARTTLS settings, like trusted roots in tests
+}
+
+// Checks that the settings can send mail
+func (c *EmailConfig) validate() error {
+	if c.Host == "" || c.From == "" || len(c.To) == 0 {
+		return fmt.Errorf("host, from and to are required")
+	}
+	if c.Port == 0 {
+		c.Port = 587
+	}
+	if c.Schedule != "" {
+		if _, err := utils.ParseCron(c.Schedule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Builds the digest of the runs recorded in historyDir between since and until and emails it
+func (c *EmailConfig) SendHistoryDigest(historyDir string, since, until time.Time) error {
+	history, err := ReadHistory(historyDir)
+	if err != nil {
+		return err
+	}
+	return c.SendDigest(BuildDigest(history, since, until))
+}
+
+// Sends the digest as a multipart email with plain text and HTML parts
+func (c *EmailConfig) SendDigest(digest *Digest) error {
+	html, err := digest.HTML()
+	if err != nil {
+		return fmt.Errorf("rendering digest: %w", err)
+	}
+	message, err := c.buildMessage(digest.Subject(), digest.Text(), html)
+	if err != nil {
+		return err
+	}
+	return c.send(message)
+}
+
+// Builds a MIME message with alternative text and HTML bodies
+func (c *EmailConfig) buildMessage(subject, text, html string) ([]byte, error) {
+	boundaryBytes := make([]byte, 12)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, err
+	}
+	boundary := "backhub-" + hex.EncodeToString(boundaryBytes)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", text},
+		{"text/html", html},
+	} {
+		fmt.Fprintf(&msg, "--%s\r\n", boundary)
+		fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", part.contentType)
+		writer := quotedprintable.NewWriter(&msg)
+		if _, err := writer.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		msg.WriteString("\r\n")
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+	return msg.Bytes(), nil
+}
+
+// Delivers a message, upgrading the connection with STARTTLS before authenticating
+func (c *EmailConfig) send(message []byte) error {
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("greeting %s: %w", addr, err)
+	}
+	defer client.Close()
+	if c.StartTLS == nil || *c.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not offer STARTTLS (set starttls: false for a local relay)", addr)
+		}
+		tlsConfig := c.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: c.Host}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	if err := client.Mail(c.From); err != nil {
+		return fmt.Errorf("sender %s: %w", c.From, err)
+	}
+	for _, to := range c.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s: %w", to, err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
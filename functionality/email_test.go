@@ -0,0 +1,203 @@
+package functionality
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Minimal SMTP server accepting one message per connection, with STARTTLS and AUTH PLAIN
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+	mutex    sync.Mutex
+	auth     string // decoded AUTH PLAIN credentials
+	usedTLS  bool
+	from     string
+	rcpt     []string
+	data     string
+}
+
+func newSMTPServer(t *testing.T) (*smtpServer, *x509.CertPool) {
+	t.Helper()
+	// Borrow httptest's certificate for 127.0.0.1
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+	tlsConfig := &tls.Config{Certificates: certServer.TLS.Certificates}
+	certServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &smtpServer{listener: listener, tls: tlsConfig}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+	return server, roots
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader, writer := bufio.NewReader(conn), bufio.NewWriter(conn)
+	reply := func(line string) {
+		writer.WriteString(line + "\r\n")
+		writer.Flush()
+	}
+	reply("220 127.0.0.1 ESMTP test")
+	secure := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0])
+		s.mutex.Lock()
+		switch verb {
+		case "EHLO", "HELO":
+			if secure {
+				reply("250-127.0.0.1\r\n250 AUTH PLAIN")
+			} else {
+				reply("250-127.0.0.1\r\n250 STARTTLS")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				s.mutex.Unlock()
+				return
+			}
+			conn, secure, s.usedTLS = tlsConn, true, true
+			reader, writer = bufio.NewReader(conn), bufio.NewWriter(conn)
+		case "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(command, "AUTH PLAIN "))
+			s.auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			s.from = command
+			reply("250 ok")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, command)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			s.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.mutex.Unlock()
+			return
+		default:
+			reply("502 unknown command")
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func TestSendDigestSTARTTLS(t *testing.T) {
+	server, roots := newSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	email := &EmailConfig{
+		Host: host, Port: portNumber, Username: "backhub", Password: "secret",
+		From: "backhub@example.com", To: []string{"ops@example.com", "lead@example.com"},
+		tlsConfig: &tls.Config{RootCAs: roots, ServerName: host},
+	}
+	since := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+	if err := email.SendDigest(BuildDigest(digestHistory(), since, since.Add(48*time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if !server.usedTLS || server.auth != "\x00backhub\x00secret" {
+		t.Errorf("TLS %v, auth %q; want STARTTLS before AUTH PLAIN", server.usedTLS, server.auth)
+	}
+	if len(server.rcpt) != 2 || !strings.Contains(server.from, "backhub@example.com") {
+		t.Errorf("from %q to %v", server.from, server.rcpt)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(server.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := msg.Header.Get("Subject"); subject != "BackHub digest 2025-03-04: 2 repositories backed up, 1 failing" {
+		t.Errorf("subject = %q", subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type %q, err %v", mediaType, err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part) // quoted-printable is decoded by the reader
+		types = append(types, strings.Split(part.Header.Get("Content-Type"), ";")[0])
+		if !strings.Contains(string(body), "authentication required") {
+			t.Errorf("%s part lacks the failure:\n%s", part.Header.Get("Content-Type"), body)
+		}
+	}
+	if strings.Join(types, ",") != "text/plain,text/html" {
+		t.Errorf("parts %v, want text/plain and text/html", types)
+	}
+}
+
+func TestSendDigestRequiresSTARTTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 plain ESMTP\r\n")
+		reader.ReadString('\n')
+		fmt.Fprint(conn, "250 plain\r\n") // no STARTTLS offered
+		reader.ReadString('\n')
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	email := &EmailConfig{Host: host, Port: portNumber, From: "a@example.com", To: []string{"b@example.com"}}
+	if err := email.SendDigest(&Digest{}); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("error = %v, want a missing STARTTLS error", err)
+	}
+}
@@ -0,0 +1,148 @@
+package functionality
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Broad classification of backup failures
+type ErrorCategory string
+
+const (
+	ErrorAuth        ErrorCategory = "auth"
+	ErrorNotFound    ErrorCategory = "not_found"
+	ErrorRateLimited ErrorCategory = "rate_limited"
+	ErrorNetwork     ErrorCategory = "network"
+	ErrorTimeout     ErrorCategory = "timeout"
+	ErrorServer      ErrorCategory = "server"
+	ErrorDiskFull    ErrorCategory = "disk_full"
+	ErrorCorrupt     ErrorCategory = "corrupt"
+	ErrorCanceled    ErrorCategory = "canceled"
+	ErrorUnknown     ErrorCategory = "unknown"
+)
+
+var categoryHints = map[ErrorCategory]string{
+	ErrorAuth:        "token is invalid, expired or lacks repo scope; check GH_TOKEN",
+	ErrorNotFound:    "repository does not exist or the token has no access to it",
+	ErrorRateLimited: "GitHub rate limit reached; set GH_TOKEN or run again later",
+	ErrorNetwork:     "check connectivity, DNS, proxy and TLS settings",
+	ErrorTimeout:     "the repository took longer than --timeout; raise it for very large repositories",
+	ErrorServer:      "GitHub returned a server error; run again later",
+	ErrorDiskFull:    "free up space in the backup folder",
+	ErrorCorrupt:     "local mirror is damaged; delete it so it is cloned again",
+	ErrorCanceled:    "the run was interrupted before the repository finished",
+	ErrorUnknown:     "run with --log-file or --debug for details",
+}
+
+// Wrapped by errors of repositories that exceeded the per-repo timeout
+var errRepoTimeout = errors.New("timed out")
+
+// Returns a short remediation hint for the category
+func (c ErrorCategory) Hint() string {
+	return categoryHints[c]
+}
+
+// Failure of a single repository together with its category
+type BackupError struct {
+	Repo     string
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *BackupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BackupError) Unwrap() error {
+	return e.Err
+}
+
+// Implements utils.CategorizedError so the summary can group failures
+func (e *BackupError) CategoryName() string {
+	return string(e.Category)
+}
+
+func (e *BackupError) Hint() string {
+	return e.Category.Hint()
+}
+
+// Reports whether an error of this category may succeed when retried
+func (c ErrorCategory) Transient() bool {
+	return c == ErrorRateLimited || c == ErrorNetwork || c == ErrorServer
+}
+
+// Classifies an error returned by go-git or the network stack
+func ClassifyError(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	message := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, errRepoTimeout):
+		return ErrorTimeout
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		// GitHub answers 403 once the rate limit is exhausted
+		if strings.Contains(message, "rate limit") {
+			return ErrorRateLimited
+		}
+		return ErrorAuth
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return ErrorNotFound
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return ErrorDiskFull
+	case errors.Is(err, git.ErrRepositoryNotExists),
+		errors.Is(err, git.ErrRepositoryIncomplete),
+		errors.Is(err, plumbing.ErrObjectNotFound),
+		errors.Is(err, packfile.ErrReferenceDeltaNotFound),
+		errors.Is(err, packfile.ErrInvalidDelta):
+		return ErrorCorrupt
+	}
+	// go-git wraps unexpected HTTP statuses without implementing Unwrap
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		var httpErr *githttp.Err
+		if errors.As(unexpected.Err, &httpErr) {
+			code := httpErr.StatusCode()
+			switch {
+			case code == 429:
+				return ErrorRateLimited
+			case code >= 500:
+				return ErrorServer
+			}
+		}
+		if category := ClassifyError(unexpected.Err); category != ErrorUnknown {
+			return category
+		}
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorNetwork
+	}
+	// Fall back to well known messages for errors that lost their type
+	if strings.Contains(message, "no space left on device") || strings.Contains(message, "disk quota exceeded") {
+		return ErrorDiskFull
+	}
+	for _, hint := range []string{"connection reset", "connection refused", "timeout", "timed out", "tls handshake", "unexpected eof", "no such host"} {
+		if strings.Contains(message, hint) {
+			return ErrorNetwork
+		}
+	}
+	return ErrorUnknown
+}
@@ -0,0 +1,76 @@
+package functionality
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Builds the error go-git returns for an HTTP response
+func httpStatusError(code int, body string) error {
+	request, _ := http.NewRequest(http.MethodPost, "https://github.com/org/repo/git-upload-pack", nil)
+	return githttp.NewErr(&http.Response{StatusCode: code, Body: io.NopCloser(strings.NewReader(body)), Request: request})
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCategory
+	}{
+		{"nil", nil, ""},
+		{"interrupted", fmt.Errorf("fetch: %w", context.Canceled), ErrorCanceled},
+		{"per-repo timeout", fmt.Errorf("%w after 30m0s: %w", errRepoTimeout, context.DeadlineExceeded), ErrorTimeout},
+		{"401", httpStatusError(401, ""), ErrorAuth},
+		{"auth required", transport.ErrAuthenticationRequired, ErrorAuth},
+		{"403", httpStatusError(403, "Permission denied"), ErrorAuth},
+		{"403 rate limit", httpStatusError(403, "API rate limit exceeded for 1.2.3.4"), ErrorRateLimited},
+		{"404", httpStatusError(404, ""), ErrorNotFound},
+		{"not found", transport.ErrRepositoryNotFound, ErrorNotFound},
+		{"429", httpStatusError(429, "Too Many Requests"), ErrorRateLimited},
+		{"500", httpStatusError(500, ""), ErrorServer},
+		{"503", httpStatusError(503, "Service Unavailable"), ErrorServer},
+		{"400", httpStatusError(400, ""), ErrorUnknown},
+		{"disk full", &os.PathError{Op: "write", Path: "objects/pack/tmp", Err: syscall.ENOSPC}, ErrorDiskFull},
+		{"quota", fmt.Errorf("write: %w", syscall.EDQUOT), ErrorDiskFull},
+		{"disk full message", errors.New("write objects/pack: no space left on device"), ErrorDiskFull},
+		{"missing mirror", git.ErrRepositoryNotExists, ErrorCorrupt},
+		{"missing object", fmt.Errorf("fetch: %w", plumbing.ErrObjectNotFound), ErrorCorrupt},
+		{"dns", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "github.com"}}, ErrorNetwork},
+		{"reset", fmt.Errorf("read: %w", syscall.ECONNRESET), ErrorNetwork},
+		{"eof", fmt.Errorf("reading pack: %w", io.ErrUnexpectedEOF), ErrorNetwork},
+		{"reset message", errors.New("read tcp: connection reset by peer"), ErrorNetwork},
+		{"other", errors.New("something odd"), ErrorUnknown},
+	}
+	for _, test := range tests {
+		if got := ClassifyError(test.err); got != test.want {
+			t.Errorf("%s: %v classified as %q, want %q", test.name, test.err, got, test.want)
+		}
+	}
+}
+
+func TestTransientCategories(t *testing.T) {
+	for category, want := range map[ErrorCategory]bool{
+		ErrorRateLimited: true, ErrorNetwork: true, ErrorServer: true,
+		ErrorAuth: false, ErrorNotFound: false, ErrorTimeout: false, ErrorCanceled: false, ErrorDiskFull: false,
+	} {
+		if category.Transient() != want {
+			t.Errorf("%s transient = %v, want %v", category, !want, want)
+		}
+		if category.Hint() == "" {
+			t.Errorf("%s has no hint", category)
+		}
+	}
+}
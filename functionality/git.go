@@ -1,98 +1,322 @@
 package functionality
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-// Handles the cloning or updating of a single repository
-func (h *Handler) backupRepo(repo, taskName string) error {
-	folderName := getLocalFolderName(repo)
+// Drift states reported when comparing a local mirror with its remote
+const (
+	driftUpToDate         = "up to date"
+	driftBehind           = "behind"
+	driftAheadOrRewritten = "ahead or rewritten"
+	driftUnreachable      = "unreachable"
+	driftCorrupt          = "corrupt"
+	driftNotMirrored      = "not mirrored"
+)
+
+// Result of comparing local mirror refs against remote refs
+type refDrift struct {
+	state     string
+	behind    int // remote refs that are new or point to objects missing locally
+	rewritten int // remote refs that point to older objects already in the mirror
+	localOnly int // refs kept in the mirror that no longer exist on the remote
+}
+
+// Handles the cloning or updating of a single repository, returning the outcome
+// and recording transfer statistics in result
+func (h *Handler) backupRepo(ctx context.Context, repo, taskName string, result *RepoResult) (Outcome, error) {
+	folderName := h.getLocalFolderName(repo)
 	repoURL := h.buildRepoURL(repo)
 	h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Preparing to backup repository from %s", repoURL))
 	h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Target directory: %s", folderName))
-	// Set up authentication if token exists
-	var auth *http.BasicAuth
-	if h.token != "" {
-		auth = &http.BasicAuth{
-			Username: "backhub",
-			Password: h.token,
-		}
-	}
+	auth := h.getAuth()
+	progress := h.newProgressWriter(taskName)
+	defer func() { result.BytesReceived = progress.counter.total.Load() }()
+	var outcome Outcome
+	var err error
 	// Check if repository exists locally
-	if _, err := os.Stat(folderName); os.IsNotExist(err) {
-		return h.cloneRepo(repoURL, folderName, auth, taskName)
+	if _, statErr := os.Stat(folderName); os.IsNotExist(statErr) {
+		outcome, err = OutcomeCloned, h.cloneRepo(ctx, repoURL, folderName, auth, taskName, progress, result)
+	} else {
+		h.outputMgr.AddStreamLine(taskName, "Repository exists locally, will update")
+		outcome, err = h.updateRepo(ctx, repoURL, folderName, auth, taskName, progress, result)
+	}
+	if err == nil {
+		if markErr := writeSuccessMarker(folderName, time.Now()); markErr != nil {
+			h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Failed to record the backup time in the mirror: %s", markErr))
+		}
 	}
-	h.outputMgr.AddStreamLine(taskName, "Repository exists locally, will update")
-	return h.updateRepo(folderName, auth, taskName)
+	return outcome, err
 }
 
 // Clones a repository as a mirror
-func (h *Handler) cloneRepo(repoURL, folderName string, auth *http.BasicAuth, taskName string) error {
+func (h *Handler) cloneRepo(ctx context.Context, repoURL, folderName string, auth *http.BasicAuth, taskName string, progress *gitProgressWriter, result *RepoResult) error {
 	h.outputMgr.SetMessage(taskName, fmt.Sprintf("Cloning %s", repoURL))
 	h.outputMgr.AddStreamLine(taskName, "Starting clone operation")
-	progress := &gitProgressWriter{
-		taskName:    taskName,
-		outputMgr:   h.outputMgr,
-		buffer:      []string{},
-		lastUpdate:  time.Now(),
-		minInterval: 500 * time.Millisecond,
-	}
-	_, err := git.PlainClone(folderName, true, &git.CloneOptions{
-		URL:      repoURL,
-		Auth:     auth,
-		Mirror:   true,
-		Progress: progress,
+	var repo *git.Repository
+	err := h.withRetry(ctx, taskName, "clone", func() error {
+		var err error
+		repo, err = git.CloneContext(ctx, newReceivingStorage(folderName, progress), nil, &git.CloneOptions{
+			URL:      repoURL,
+			Auth:     auth,
+			Mirror:   true,
+			Progress: progress,
+		})
+		if err != nil {
+			os.RemoveAll(folderName) // drop the partial mirror so the next attempt starts clean
+		}
+		return err
 	})
+	progress.Flush()
 	if err != nil {
 		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Clone failed: %s", err))
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
+	if refs, err := localRefs(repo); err == nil {
+		result.RefsChanged = len(refs)
+	}
 	h.outputMgr.AddStreamLine(taskName, "Clone completed successfully")
 	h.outputMgr.SetMessage(taskName, fmt.Sprintf("Successfully cloned %s", repoURL))
 	return nil
 }
 
-// Updates an existing repository
-func (h *Handler) updateRepo(folderName string, auth *http.BasicAuth, taskName string) error {
+// Updates an existing repository, skipping the fetch when remote refs match the mirror
+func (h *Handler) updateRepo(ctx context.Context, repoURL, folderName string, auth *http.BasicAuth, taskName string, progress *gitProgressWriter, result *RepoResult) (Outcome, error) {
 	h.outputMgr.SetMessage(taskName, fmt.Sprintf("Updating %s", folderName))
 	h.outputMgr.AddStreamLine(taskName, "Opening local repository")
-	repo, err := git.PlainOpen(folderName)
+	repo, err := openMirror(folderName, progress)
 	if err != nil {
 		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Failed to open repository: %s", err))
-		return fmt.Errorf("failed to open repository: %w", err)
+		return OutcomeUpdated, fmt.Errorf("failed to open repository: %w", err)
+	}
+	if h.refsUnchanged(ctx, repo, repoURL, auth, taskName) {
+		h.outputMgr.AddStreamLine(taskName, "All remote refs match the local mirror, skipping fetch")
+		h.outputMgr.SetMessage(taskName, fmt.Sprintf("Repository %s is unchanged", folderName))
+		return OutcomeUnchanged, nil
 	}
 	h.outputMgr.AddStreamLine(taskName, "Fetching updates from remote")
-	progress := &gitProgressWriter{
-		taskName:    taskName,
-		outputMgr:   h.outputMgr,
-		buffer:      []string{},
-		lastUpdate:  time.Now(),
-		minInterval: 500 * time.Millisecond,
-	}
-	err = repo.Fetch(&git.FetchOptions{
-		Auth:     auth,
-		Force:    true,
-		Progress: progress,
-		Tags:     git.AllTags,
+	before, _ := localRefs(repo)
+	err = h.withRetry(ctx, taskName, "fetch", func() error {
+		return repo.FetchContext(ctx, &git.FetchOptions{
+			Auth:     auth,
+			Force:    true,
+			Progress: progress,
+			Tags:     git.AllTags,
+		})
 	})
+	progress.Flush()
 	if err == git.NoErrAlreadyUpToDate {
 		h.outputMgr.AddStreamLine(taskName, "Repository already up to date")
 		h.outputMgr.SetMessage(taskName, fmt.Sprintf("Repository %s is already up to date", folderName))
-		return nil
+		return OutcomeUnchanged, nil
 	}
 	if err != nil {
 		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Fetch failed: %s", err))
-		return fmt.Errorf("failed to fetch updates: %w", err)
+		return OutcomeUpdated, fmt.Errorf("failed to fetch updates: %w", err)
+	}
+	if after, err := localRefs(repo); err == nil {
+		result.RefsChanged = countChangedRefs(before, after)
+		result.RefsRewritten = countRewrittenRefs(repo, before, after)
+	}
+	if result.RefsRewritten > 0 {
+		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("History rewritten on the remote: %d refs were force-pushed", result.RefsRewritten))
 	}
 	h.outputMgr.AddStreamLine(taskName, "Repository updated successfully")
 	h.outputMgr.SetMessage(taskName, fmt.Sprintf("Successfully updated %s", folderName))
-	return nil
+	return OutcomeUpdated, nil
+}
+
+// Fast path check that lists remote refs and compares them with the mirror; failures fall back to a fetch
+func (h *Handler) refsUnchanged(ctx context.Context, repo *git.Repository, repoURL string, auth *http.BasicAuth, taskName string) bool {
+	h.outputMgr.AddStreamLine(taskName, "Comparing remote refs with local mirror")
+	local, err := localRefs(repo)
+	if err != nil {
+		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Failed to read local refs, falling back to fetch: %s", err))
+		return false
+	}
+	remote, err := listRemoteRefs(ctx, repoURL, auth)
+	if err != nil {
+		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Failed to list remote refs, falling back to fetch: %s", err))
+		return false
+	}
+	drift := compareRefs(repo, local, remote)
+	return drift.state == driftUpToDate
+}
+
+// Builds basic auth from the token if one is set
+func (h *Handler) getAuth() *http.BasicAuth {
+	if h.token == "" {
+		return nil
+	}
+	return &http.BasicAuth{
+		Username: "backhub",
+		Password: h.token,
+	}
+}
+
+// Lists remote refs without downloading any objects (ls-remote equivalent)
+func listRemoteRefs(ctx context.Context, repoURL string, auth *http.BasicAuth) (map[string]plumbing.Hash, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repoURL},
+	})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, err
+	}
+	return hashRefs(refs), nil
+}
+
+// Collects the refs stored in a local mirror
+func localRefs(repo *git.Repository) (map[string]plumbing.Hash, error) {
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hashRefs(refs), nil
+}
+
+// Keeps only direct hash references, dropping HEAD and other symbolic refs
+func hashRefs(refs []*plumbing.Reference) map[string]plumbing.Hash {
+	result := make(map[string]plumbing.Hash, len(refs))
+	for _, ref := range refs {
+		if ref.Type() != plumbing.HashReference || ref.Name() == plumbing.HEAD {
+			continue
+		}
+		result[ref.Name().String()] = ref.Hash()
+	}
+	return result
+}
+
+// Counts refs that were added, moved or removed between two snapshots
+func countChangedRefs(before, after map[string]plumbing.Hash) int {
+	changed := 0
+	for name, hash := range after {
+		if previous, exists := before[name]; !exists || previous != hash {
+			changed++
+		}
+	}
+	for name := range before {
+		if _, exists := after[name]; !exists {
+			changed++
+		}
+	}
+	return changed
+}
+
+// Reports whether a ref is a branch or tag, whose history should only move forward;
+// refs such as refs/pull/N/merge are recreated by the host whenever it likes
+func isHistoryRef(name string) bool {
+	return strings.HasPrefix(name, "refs/heads/") || strings.HasPrefix(name, "refs/tags/")
+}
+
+// Counts branches and tags that moved to a commit not descending from their previous one, like after a force push
+func countRewrittenRefs(repo *git.Repository, before, after map[string]plumbing.Hash) int {
+	rewritten := 0
+	for name, hash := range after {
+		previous, exists := before[name]
+		if !exists || previous == hash || !isHistoryRef(name) {
+			continue
+		}
+		oldCommit, oldErr := repo.CommitObject(previous)
+		newCommit, newErr := repo.CommitObject(hash)
+		if oldErr != nil || newErr != nil { // moved tags and other non-commit refs
+			rewritten++
+			continue
+		}
+		if ancestor, err := oldCommit.IsAncestor(newCommit); err != nil || !ancestor {
+			rewritten++
+		}
+	}
+	return rewritten
+}
+
+// Returns the total size of the files below a directory
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // count what is readable
+		}
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// Compares local and remote refs; objects present locally distinguish rewrites from new commits
+func compareRefs(repo *git.Repository, local, remote map[string]plumbing.Hash) refDrift {
+	var drift refDrift
+	for name, remoteHash := range remote {
+		localHash, exists := local[name]
+		if exists && localHash == remoteHash {
+			continue
+		}
+		// A moved pull request ref is new work, not a rewrite
+		if exists && isHistoryRef(name) && repo.Storer.HasEncodedObject(remoteHash) == nil {
+			drift.rewritten++
+		} else {
+			drift.behind++
+		}
+	}
+	// Refs deleted upstream are kept by the mirror since fetches don't prune
+	for name := range local {
+		if _, exists := remote[name]; !exists {
+			drift.localOnly++
+		}
+	}
+	switch {
+	case drift.rewritten > 0:
+		drift.state = driftAheadOrRewritten
+	case drift.behind > 0:
+		drift.state = driftBehind
+	default:
+		drift.state = driftUpToDate
+	}
+	return drift
+}
+
+// Checks a local mirror against its remote without fetching objects
+func (h *Handler) checkDrift(ctx context.Context, repo string) (refDrift, error) {
+	folderName := h.getLocalFolderName(repo)
+	if _, err := os.Stat(folderName); os.IsNotExist(err) {
+		return refDrift{state: driftNotMirrored}, nil
+	}
+	localRepo, err := git.PlainOpen(folderName)
+	if err != nil {
+		return refDrift{state: driftCorrupt}, fmt.Errorf("failed to open repository: %w", err)
+	}
+	local, err := localRefs(localRepo)
+	if err != nil {
+		return refDrift{state: driftCorrupt}, fmt.Errorf("failed to read local refs: %w", err)
+	}
+	remote, err := listRemoteRefs(ctx, h.buildRepoURL(repo), h.getAuth())
+	if err != nil {
+		return refDrift{state: driftUnreachable}, fmt.Errorf("failed to list remote refs: %w", err)
+	}
+	return compareRefs(localRepo, local, remote), nil
 }
 
 // Constructs the HTTPS URL for a repository
@@ -101,7 +325,29 @@ func (h *Handler) buildRepoURL(repo string) string {
 }
 
 // Generates the local folder name for a repository
-func getLocalFolderName(repo string) string {
-	base := filepath.Base(repo)
-	return base + ".git"
+func (h *Handler) getLocalFolderName(repo string) string {
+	return mirrorPath(h.cloneFolder, repo)
+}
+
+// Returns where the mirror of a repository lives below dir
+func mirrorPath(dir, repo string) string {
+	return filepath.Join(dir, filepath.Base(repo)+".git")
+}
+
+// File in a mirror holding the time of its latest successful backup
+const successMarker = "backhub-last-success"
+
+// Records a successful backup in the mirror, so its freshness is known without the run history
+func writeSuccessMarker(mirror string, t time.Time) error {
+	return os.WriteFile(filepath.Join(mirror, successMarker), []byte(t.UTC().Format(time.RFC3339)+"\n"), 0644)
+}
+
+// Returns the time recorded by writeSuccessMarker, zero if the mirror has none
+func readSuccessMarker(mirror string) time.Time {
+	data, err := os.ReadFile(filepath.Join(mirror, successMarker))
+	if err != nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	return t
 }
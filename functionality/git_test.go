@@ -0,0 +1,107 @@
+package functionality
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Creates a repository with a function adding empty commits to it
+func newTestRepo(t *testing.T) (*git.Repository, func(message string, parents ...plumbing.Hash) plumbing.Hash) {
+	t.Helper()
+	repo, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, func(message string, parents ...plumbing.Hash) plumbing.Hash {
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			Parents:           parents,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+}
+
+func TestCountRewrittenRefs(t *testing.T) {
+	repo, commit := newTestRepo(t)
+	root := commit("root")
+	old := commit("old", root)
+	forward := commit("forward", old)
+	rewrite := commit("rewrite", root)
+
+	before := map[string]plumbing.Hash{"refs/heads/main": old, "refs/heads/dev": old, "refs/tags/v1": old, "refs/pull/7/merge": old, "refs/pull/7/head": old}
+	after := map[string]plumbing.Hash{"refs/heads/main": forward, "refs/heads/dev": rewrite, "refs/heads/new": rewrite, "refs/tags/v1": rewrite, "refs/pull/7/merge": rewrite, "refs/pull/7/head": rewrite}
+	if got := countRewrittenRefs(repo, before, after); got != 2 {
+		t.Errorf("counted %d rewritten refs, want 2 (dev and v1, not the pull request refs)", got)
+	}
+}
+
+func TestCompareRefs(t *testing.T) {
+	repo, commit := newTestRepo(t)
+	old := commit("old")
+	current := commit("current", old)
+	missing := plumbing.NewHash("1111111111111111111111111111111111111111")
+
+	tests := []struct {
+		name   string
+		local  map[string]plumbing.Hash
+		remote map[string]plumbing.Hash
+		want   refDrift
+	}{
+		{"up to date", map[string]plumbing.Hash{"refs/heads/main": current}, map[string]plumbing.Hash{"refs/heads/main": current},
+			refDrift{state: driftUpToDate}},
+		{"new commits", map[string]plumbing.Hash{"refs/heads/main": current}, map[string]plumbing.Hash{"refs/heads/main": missing, "refs/heads/dev": missing},
+			refDrift{state: driftBehind, behind: 2}},
+		{"reset to a local commit", map[string]plumbing.Hash{"refs/heads/main": current}, map[string]plumbing.Hash{"refs/heads/main": old},
+			refDrift{state: driftAheadOrRewritten, rewritten: 1}},
+		{"pull request ref moved back", map[string]plumbing.Hash{"refs/pull/7/merge": current}, map[string]plumbing.Hash{"refs/pull/7/merge": old},
+			refDrift{state: driftBehind, behind: 1}},
+		{"deleted upstream", map[string]plumbing.Hash{"refs/heads/main": current, "refs/heads/gone": old}, map[string]plumbing.Hash{"refs/heads/main": current},
+			refDrift{state: driftUpToDate, localOnly: 1}},
+	}
+	for _, test := range tests {
+		if got := compareRefs(repo, test.local, test.remote); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRefsUnchanged(t *testing.T) {
+	server, _ := newRefsServer(t)
+	handler := newStatusHandler(t)
+	repo, err := git.PlainInit(t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setMain := func(hash string) {
+		ref := plumbing.NewHashReference("refs/heads/main", plumbing.NewHash(hash))
+		if err := repo.Storer.SetReference(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setMain(strings.Repeat("1", 40)) // the commit the server advertises
+	if !handler.refsUnchanged(context.Background(), repo, server.URL+"/org/repo", nil, "repo") {
+		t.Error("mirror matching the remote refs is not skipped")
+	}
+	setMain(strings.Repeat("2", 40))
+	if handler.refsUnchanged(context.Background(), repo, server.URL+"/org/repo", nil, "repo") {
+		t.Error("mirror behind the remote is skipped")
+	}
+	if handler.refsUnchanged(context.Background(), repo, "http://127.0.0.1:1/org/repo", nil, "repo") {
+		t.Error("unreachable remote is skipped instead of falling back to a fetch")
+	}
+}
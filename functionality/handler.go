@@ -1,9 +1,13 @@
 package functionality
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -13,77 +17,205 @@ import (
 )
 
 type Config struct {
-	Repos []string `yaml:"repos"`
+	Repos         []RepoEntry    `yaml:"repos"`
+	Notifications []NotifyTarget `yaml:"notifications"`
+	Email         *EmailConfig   `yaml:"email"`
 }
 
-type Handler struct {
-	token       string
-	outputMgr   *utils.Manager
-	concurrency int
-	repos       []string
-	cloneFolder string
-}
+// Direct repository paths accepted in place of a config file
+const repoRegex = "^github.com/[^/]+/[^/]+$"
 
-// Implements io.Writer to capture git operation progress
-type gitProgressWriter struct {
-	taskName    string
-	outputMgr   *utils.Manager
-	buffer      []string
-	lastUpdate  time.Time
-	minInterval time.Duration
+// Repository entry from the config; a plain string is accepted as the URL
+type RepoEntry struct {
+	URL      string        `yaml:"url"`
+	Interval time.Duration `yaml:"interval"` // Daemon mode: back up at most this often
+	Cron     string        `yaml:"cron"`     // Daemon mode: back up on this cron schedule
+	Priority int           `yaml:"priority"` // Higher priority repos are dispatched first
 }
 
-// Implements io.Writer interface to capture git progress messages
-func (p *gitProgressWriter) Write(data []byte) (int, error) {
-	message := strings.TrimSpace(string(data))
-	if message == "" {
-		return len(data), nil
-	}
-	p.buffer = append(p.buffer, message)
-	if len(p.buffer) > 5 { // Keep the last 5 messages
-		p.buffer = p.buffer[len(p.buffer)-5:]
-	}
-	now := time.Now()
-	if now.Sub(p.lastUpdate) >= p.minInterval {
-		p.outputMgr.UpdateStreamOutput(p.taskName, p.buffer)
-		p.lastUpdate = now
+func (r *RepoEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&r.URL)
 	}
-	return len(data), nil
+	type plainEntry RepoEntry
+	return node.Decode((*plainEntry)(r))
+}
+
+// Receives task progress from a handler; *utils.Manager is the default implementation
+type ProgressSink interface {
+	Register(name string)
+	SetMessage(name, message string)
+	SetStatus(name, status string)
+	AddStreamLine(name, line string)
+	UpdateStreamOutput(name string, output []string)
+	Complete(name string)
+	ReportError(name string, err error)
+}
+
+// Optional interface for sinks that draw a live display, like *utils.Manager
+type displaySink interface {
+	SetUnlimitedOutput(unlimited bool)
+	StartDisplay()
+	StopDisplay()
+}
+
+// Optional interface for sinks that display tables, like *utils.Manager
+type tableSink interface {
+	RegisterTable(name string, headers []string) *utils.Table
+}
+
+// Optional interface for sinks that show run-level progress, like *utils.Manager
+type overallSink interface {
+	TrackOverall(name string, total int)
+	SetBytesReceived(name string, bytes int64)
+	SetExpectedDuration(name string, expected time.Duration)
+}
+
+// Optional interface for sinks that nest tasks under groups, like *utils.Manager
+type groupSink interface {
+	RegisterGroup(name string, size int)
+	RegisterChild(name, parent string)
+	FinishGroup(name string)
+}
+
+type Handler struct {
+	token          string
+	outputMgr      ProgressSink
+	concurrency    int
+	repos          []RepoEntry
+	cloneFolder    string
+	retries        int                      // Extra attempts for transient clone/fetch errors
+	retryBaseDelay time.Duration            // Delay before the first retry, doubled per attempt
+	repoTimeout    time.Duration            // Upper bound for backing up a single repo, 0 disables it
+	durationHints  map[string]time.Duration // Per-repo durations of earlier runs, for the ETA
 }
 
 func NewHandler(token string) *Handler {
 	return &Handler{
-		token:       token,
-		outputMgr:   utils.NewManager(15),
-		concurrency: 5,
-		cloneFolder: ".",
+		token:          token,
+		outputMgr:      utils.NewManager(15),
+		concurrency:    5,
+		cloneFolder:    ".",
+		retries:        2,
+		retryBaseDelay: 2 * time.Second,
+		repoTimeout:    30 * time.Minute,
+	}
+}
+
+// Replaces the default output manager, e.g. to collect events instead of drawing a display
+func (h *Handler) SetProgressSink(sink ProgressSink) {
+	h.outputMgr = sink
+}
+
+// Forwards the unlimited output mode to the display, if the sink draws one
+func (h *Handler) SetUnlimitedOutput(unlimited bool) {
+	if display, ok := h.outputMgr.(displaySink); ok {
+		display.SetUnlimitedOutput(unlimited)
 	}
 }
 
+// Sets the number of repositories backed up in parallel
+func (h *Handler) SetConcurrency(concurrency int) {
+	h.concurrency = max(concurrency, 1)
+}
+
+// Sets the directory in which the mirrors are created
+func (h *Handler) SetCloneFolder(folder string) {
+	h.cloneFolder = folder
+}
+
+// Sets the maximum time a single repository may take, 0 disables the timeout
+func (h *Handler) SetRepoTimeout(timeout time.Duration) {
+	h.repoTimeout = timeout
+}
+
+// Sets how long each repository took in earlier runs, used to estimate the run's ETA
+func (h *Handler) SetDurationHints(hints map[string]time.Duration) {
+	h.durationHints = hints
+}
+
+// Sets how often transient clone/fetch errors are retried and the initial backoff
+func (h *Handler) SetRetryPolicy(retries int, baseDelay time.Duration) {
+	h.retries = max(retries, 0)
+	h.retryBaseDelay = baseDelay
+}
+
 func (h *Handler) Setup() {
 	h.outputMgr.Register("logistics")
 	h.outputMgr.SetMessage("logistics", "Setting up BackHub")
-	h.outputMgr.StartDisplay()
+	if display, ok := h.outputMgr.(displaySink); ok {
+		display.StartDisplay()
+	}
 }
 
-// Loads repository configuration from a file or direct repo path
-func (h *Handler) LoadConfig(path string) error {
-	h.outputMgr.AddStreamLine("logistics", fmt.Sprintf("Loading configuration from '%s'", path))
-	repoRegex := "^github.com/[^/]+/[^/]+$"
+// Stops the live display, if the sink draws one
+func (h *Handler) stopDisplay() {
+	if display, ok := h.outputMgr.(displaySink); ok {
+		display.StopDisplay()
+	}
+}
+
+// Reads and validates the config file; a direct repo path is a config with only that repo
+func ReadConfig(path string) (*Config, error) {
 	if regexp.MustCompile(repoRegex).MatchString(path) {
-		h.repos = []string{path}
-		h.outputMgr.AddStreamLine("logistics", "Direct repo specified, using it as configuration")
-		return nil
+		return &Config{Repos: []RepoEntry{{URL: path}}}, nil
 	}
 	data, err := os.ReadFile(path)
 	if err != nil {
-		h.outputMgr.AddStreamLine("logistics", "Failed to read config file")
-		return fmt.Errorf("reading config file: %w", err)
+		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		h.outputMgr.AddStreamLine("logistics", "Failed to parse YAML configuration")
-		return fmt.Errorf("parsing config: %w", err)
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if password := os.Getenv("SMTP_PASSWORD"); password != "" && cfg.Email != nil {
+		cfg.Email.Password = password
+	}
+	return &cfg, nil
+}
+
+// Checks every section of the config, filling in defaults
+func (c *Config) validate() error {
+	for _, entry := range c.Repos {
+		if entry.URL == "" {
+			return fmt.Errorf("repo entry without url")
+		}
+		if entry.Interval != 0 && entry.Cron != "" {
+			return fmt.Errorf("%s sets both interval and cron", entry.URL)
+		}
+		if entry.Cron != "" {
+			if _, err := utils.ParseCron(entry.Cron); err != nil {
+				return fmt.Errorf("%s: %w", entry.URL, err)
+			}
+		}
+	}
+	for idx := range c.Notifications {
+		target := &c.Notifications[idx]
+		if target.On == "" {
+			target.On = TriggerAlways
+		}
+		if err := target.validate(); err != nil {
+			return fmt.Errorf("notification %d: %w", idx+1, err)
+		}
+	}
+	if c.Email != nil {
+		if err := c.Email.validate(); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	return nil
+}
+
+// Loads repository configuration from a file or direct repo path
+func (h *Handler) LoadConfig(path string) error {
+	h.outputMgr.AddStreamLine("logistics", fmt.Sprintf("Loading configuration from '%s'", path))
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		h.outputMgr.AddStreamLine("logistics", "Failed to load configuration")
+		return err
 	}
 	h.repos = cfg.Repos
 	h.outputMgr.AddStreamLine("logistics", fmt.Sprintf("Loaded %d repositories", len(h.repos)))
@@ -102,63 +234,198 @@ func (h *Handler) ValidateToken() error {
 	return nil
 }
 
+// Registers a group task per owner (host and org or user) when the repositories
+// span several owners; returns the group task of each repository
+func (h *Handler) registerGroups(repos []RepoEntry) map[string]string {
+	grouper, ok := h.outputMgr.(groupSink)
+	if !ok {
+		return nil
+	}
+	var owners []string
+	members := make(map[string]int)
+	for _, repo := range repos {
+		owner := repoOwner(repo.URL)
+		if members[owner] == 0 {
+			owners = append(owners, owner)
+		}
+		members[owner]++
+	}
+	if len(owners) < 2 {
+		return nil
+	}
+	groups := make(map[string]string, len(repos))
+	for _, owner := range owners {
+		name := fmt.Sprintf("group-%s", owner)
+		grouper.RegisterGroup(name, members[owner])
+		h.outputMgr.SetMessage(name, owner)
+	}
+	for _, repo := range repos {
+		groups[repo.URL] = fmt.Sprintf("group-%s", repoOwner(repo.URL))
+	}
+	return groups
+}
+
+// Returns the host and owner part of a repository URL, like github.com/org
+func repoOwner(repo string) string {
+	repo = strings.TrimSuffix(repo, ".git")
+	if _, rest, found := strings.Cut(repo, "://"); found {
+		repo = rest
+	}
+	return path.Dir(repo)
+}
+
 // Performs the backup operation for all repositories
-func (h *Handler) ExecuteBackup() error {
+func (h *Handler) ExecuteBackup(ctx context.Context) *RunResult {
 	repoCount := len(h.repos)
-	wg := &sync.WaitGroup{}
-	toProcess := make(chan string, repoCount)
+	result := &RunResult{StartTime: time.Now(), Repos: make([]RepoResult, repoCount)}
 
 	h.outputMgr.SetMessage("logistics", fmt.Sprintf("Processing %d repositories", repoCount))
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for _, repo := range h.repos {
-			toProcess <- repo
+	overall, hasOverall := h.outputMgr.(overallSink)
+	if hasOverall {
+		overall.TrackOverall("logistics", repoCount)
+	}
+	// Dispatch highest priority first, keeping config order otherwise
+	queue := slices.Clone(h.repos)
+	slices.SortStableFunc(queue, func(a, b RepoEntry) int {
+		return b.Priority - a.Priority
+	})
+	groups := h.registerGroups(queue)
+
+	// Each worker only writes its own slots of result.Repos
+	h.dispatch(len(queue), func(idx int) {
+		repo := queue[idx].URL
+		repoResult := &result.Repos[idx]
+		repoResult.Repo = repo
+		repoResult.StartTime = time.Now()
+		// Stop dispatching new repos once cancelled
+		if ctx.Err() != nil {
+			repoResult.Outcome = OutcomeSkipped
+			repoResult.EndTime = repoResult.StartTime
+			return
+		}
+		taskName := fmt.Sprintf("repo-%s", repo)
+		if group, exists := groups[repo]; exists {
+			h.outputMgr.(groupSink).RegisterChild(taskName, group)
+		} else {
+			h.outputMgr.Register(taskName)
+		}
+		h.outputMgr.SetMessage(taskName, fmt.Sprintf("Processing %s", repo))
+		if expected, exists := h.durationHints[repo]; exists && hasOverall {
+			overall.SetExpectedDuration(taskName, expected)
+		}
+		outcome, err := h.backupRepoWithTimeout(ctx, repo, taskName, repoResult)
+		repoResult.EndTime = time.Now()
+		repoResult.Duration = repoResult.EndTime.Sub(repoResult.StartTime)
+		if err != nil {
+			repoResult.Outcome = OutcomeFailed
+			repoResult.Error = err.Error()
+			repoResult.ErrorCategory = ClassifyError(err)
+			if ctx.Err() != nil { // the signal cause may hide context.Canceled
+				repoResult.ErrorCategory = ErrorCanceled
+			}
+			h.outputMgr.ReportError(taskName, &BackupError{Repo: repo, Category: repoResult.ErrorCategory, Err: err})
+			return
+		}
+		repoResult.Outcome = outcome
+		repoResult.MirrorSize = dirSize(h.getLocalFolderName(repo))
+		if outcome == OutcomeUnchanged {
+			h.outputMgr.SetMessage(taskName, fmt.Sprintf("%s unchanged, fetch skipped", repo))
+		} else {
+			h.outputMgr.SetMessage(taskName, fmt.Sprintf("%s backed up successfully", repo))
 		}
-		close(toProcess)
-	}()
+		h.outputMgr.Complete(taskName)
+	})
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
+	result.Interrupted = ctx.Err() != nil
+	if result.Interrupted {
+		// Skipped repos never join their group, which would otherwise stay running
+		for _, group := range groups {
+			h.outputMgr.(groupSink).FinishGroup(group)
+		}
+	}
+	if tables, ok := h.outputMgr.(tableSink); ok {
+		table := tables.RegisterTable("Backup Results", resultHeaders)
+		table.Rows = result.TableRows()
+	}
+
+	// Final summary
+	summary := fmt.Sprintf("%d cloned, %d updated, %d unchanged, %d failed",
+		result.Count(OutcomeCloned), result.Count(OutcomeUpdated), result.Count(OutcomeUnchanged), result.Count(OutcomeFailed))
+	if result.Interrupted {
+		h.outputMgr.SetMessage("logistics", fmt.Sprintf("Backup process interrupted: %s, %d skipped", summary, result.Count(OutcomeSkipped)))
+		h.outputMgr.Complete("logistics")
+		h.outputMgr.SetStatus("logistics", "warning")
+	} else {
+		h.outputMgr.SetMessage("logistics", fmt.Sprintf("Backup process completed: %s", summary))
+		h.outputMgr.Complete("logistics")
+	}
+	h.stopDisplay()
+	return result
+}
 
-	// Start consumer pool
-	for i := range h.concurrency {
+// Calls work for every index below count on a pool of h.concurrency workers and
+// waits until all of them are done
+func (h *Handler) dispatch(count int, work func(idx int)) {
+	toProcess := make(chan int, count)
+	for idx := range count {
+		toProcess <- idx
+	}
+	close(toProcess)
+	wg := &sync.WaitGroup{}
+	for range h.concurrency {
 		wg.Add(1)
-		go func(workerId int) {
+		go func() {
 			defer wg.Done()
-			for repo := range toProcess {
-				taskName := fmt.Sprintf("repo-%s", repo)
-				h.outputMgr.Register(taskName)
-				h.outputMgr.SetMessage(taskName, fmt.Sprintf("Processing %s", repo))
-				if err := h.backupRepo(repo, taskName); err != nil {
-					h.outputMgr.ReportError(taskName, err)
-				} else {
-					h.outputMgr.SetMessage(taskName, fmt.Sprintf("%s backed up successfully", repo))
-					h.outputMgr.Complete(taskName)
-				}
+			for idx := range toProcess {
+				work(idx)
 			}
-		}(i)
+		}()
 	}
 	wg.Wait()
+}
 
-	// Final summary
-	h.outputMgr.SetMessage("logistics", "Backup process completed")
-	h.outputMgr.Complete("logistics")
-	h.outputMgr.StopDisplay()
-	return nil
+// Backs up a repository under the per-repo timeout, if one is set
+func (h *Handler) backupRepoWithTimeout(ctx context.Context, repo, taskName string, result *RepoResult) (Outcome, error) {
+	if h.repoTimeout <= 0 {
+		return h.backupRepo(ctx, repo, taskName, result)
+	}
+	repoCtx, cancel := context.WithTimeout(ctx, h.repoTimeout)
+	defer cancel()
+	action, err := h.backupRepo(repoCtx, repo, taskName, result)
+	if err != nil && ctx.Err() == nil && errors.Is(repoCtx.Err(), context.DeadlineExceeded) {
+		return action, fmt.Errorf("%w after %s: %w", errRepoTimeout, h.repoTimeout, err)
+	}
+	return action, err
+}
+
+// Entry point to back up an already selected set of repositories
+func (h *Handler) RunBackupRepos(ctx context.Context, repos []RepoEntry) (*RunResult, error) {
+	h.Setup()
+	if err := h.ValidateToken(); err != nil {
+		h.outputMgr.ReportError("logistics", err)
+		h.stopDisplay()
+		return nil, err
+	}
+	h.repos = repos
+	h.outputMgr.AddStreamLine("logistics", fmt.Sprintf("Backing up %d selected repositories", len(h.repos)))
+	h.outputMgr.SetMessage("logistics", "Backup logistics completed")
+	return h.ExecuteBackup(ctx), nil
 }
 
 // Entry point to run the backup process
-func (h *Handler) RunBackup(configPath string, unlimitedOutput bool) error {
-	h.outputMgr.SetUnlimitedOutput(unlimitedOutput)
+func (h *Handler) RunBackup(ctx context.Context, configPath string) (*RunResult, error) {
 	h.Setup()
 	if err := h.ValidateToken(); err != nil {
 		h.outputMgr.ReportError("logistics", err)
-		h.outputMgr.StopDisplay()
-		return err
+		h.stopDisplay()
+		return nil, err
 	}
 	if err := h.LoadConfig(configPath); err != nil {
 		h.outputMgr.ReportError("logistics", err)
-		h.outputMgr.StopDisplay()
-		return err
+		h.stopDisplay()
+		return nil, err
 	}
 	h.outputMgr.SetMessage("logistics", "Backup logistics completed")
-	return h.ExecuteBackup()
+	return h.ExecuteBackup(ctx), nil
 }
@@ -0,0 +1,63 @@
+package functionality
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+func TestExecuteBackupCancelled(t *testing.T) {
+	handler := newStatusHandler(t, "github.com/org/a", "github.com/org/b")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := handler.ExecuteBackup(ctx)
+	if !result.Interrupted || result.Count(OutcomeSkipped) != 2 {
+		t.Errorf("cancelled run %+v, want interrupted with both repositories skipped", result)
+	}
+}
+
+func TestExecuteBackupCancelledFinishesGroups(t *testing.T) {
+	handler := newStatusHandler(t, "github.com/org/a", "github.com/other/b")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler.ExecuteBackup(ctx)
+	manager := handler.outputMgr.(*utils.Manager)
+	for _, group := range []string{"group-github.com/org", "group-github.com/other"} {
+		if status := manager.GetStatus(group); status != "warning" {
+			t.Errorf("%s has status %q after the run was interrupted, want warning", group, status)
+		}
+	}
+}
+
+func TestExecuteBackupTimeout(t *testing.T) {
+	// Accepts connections but never answers, like a stalled remote
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	handler := newStatusHandler(t, listener.Addr().String()+"/org/stalled")
+	handler.SetRetryPolicy(0, 0)
+	handler.SetRepoTimeout(200 * time.Millisecond)
+	result := handler.ExecuteBackup(context.Background())
+	repo := result.Repos[0]
+	if repo.Outcome != OutcomeFailed || repo.ErrorCategory != ErrorTimeout || result.Interrupted {
+		t.Errorf("stalled repository %+v (interrupted %v), want a timeout failure", repo, result.Interrupted)
+	}
+	if repo.Duration > 5*time.Second {
+		t.Errorf("timeout took %s", repo.Duration)
+	}
+}
@@ -0,0 +1,160 @@
+package functionality
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// File in the backup root to which every run's result is appended as a JSON line
+const HistoryFile = ".backhub-history.jsonl"
+
+// Appends a run's result to the history file in dir
+func AppendHistory(dir string, result *RunResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, HistoryFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening history: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return file.Close()
+}
+
+// Reads the recorded runs in dir, oldest first; a missing history file has no runs
+func ReadHistory(dir string) ([]RunResult, error) {
+	file, err := os.Open(filepath.Join(dir, HistoryFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	defer file.Close()
+	var runs []RunResult
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // a line holds every repo of a run
+	for scanner.Scan() {
+		var run RunResult
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue // a line cut short by a crash or full disk; later runs are still valid
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	return runs, nil
+}
+
+// Number of recent successful backups averaged for a repository's duration trend
+const trendWindow = 5
+
+// Record of a single repository across the run history
+type RepoHistory struct {
+	Repo          string          `json:"repo"`
+	LastRun       time.Time       `json:"last_run"`
+	LastOutcome   Outcome         `json:"last_outcome"`
+	LastSuccess   time.Time       `json:"last_success"` // zero if never backed up
+	LastError     string          `json:"last_error,omitempty"`
+	FailureStreak int             `json:"failure_streak"` // failed attempts since the last success
+	Durations     []time.Duration `json:"durations_ns"`   // recent successful backups, oldest first
+}
+
+// Summarizes each repository of the history, sorted by name; skipped attempts are ignored
+func SummarizeHistory(runs []RunResult) []RepoHistory {
+	repos := make(map[string]*RepoHistory)
+	for _, run := range runs {
+		for _, repo := range run.Repos {
+			if repo.Outcome == OutcomeSkipped {
+				continue
+			}
+			summary, exists := repos[repo.Repo]
+			if !exists {
+				summary = &RepoHistory{Repo: repo.Repo}
+				repos[repo.Repo] = summary
+			}
+			summary.LastRun, summary.LastOutcome = run.StartTime, repo.Outcome
+			if repo.Outcome == OutcomeFailed {
+				summary.FailureStreak++
+				summary.LastError = repo.Error
+				continue
+			}
+			summary.LastSuccess, summary.FailureStreak, summary.LastError = run.StartTime, 0, ""
+			summary.Durations = append(summary.Durations, repo.Duration)
+			if len(summary.Durations) > 2*trendWindow {
+				summary.Durations = summary.Durations[len(summary.Durations)-2*trendWindow:]
+			}
+		}
+	}
+	summaries := make([]RepoHistory, 0, len(repos))
+	for _, summary := range repos {
+		summaries = append(summaries, *summary)
+	}
+	slices.SortFunc(summaries, func(a, b RepoHistory) int { return strings.Compare(a.Repo, b.Repo) })
+	return summaries
+}
+
+// Returns the average of the recent successful backups, 0 without any
+func (r RepoHistory) AverageDuration() time.Duration {
+	recent := r.Durations[max(len(r.Durations)-trendWindow, 0):]
+	return averageDuration(recent)
+}
+
+// Returns the relative change of the recent average duration against the
+// window before it, like 0.25 for 25% slower; ok is false without enough runs
+func (r RepoHistory) Trend() (change float64, ok bool) {
+	if len(r.Durations) < trendWindow+1 {
+		return 0, false
+	}
+	split := len(r.Durations) - trendWindow
+	previous := averageDuration(r.Durations[:split])
+	if previous == 0 {
+		return 0, false
+	}
+	return float64(r.AverageDuration()-previous) / float64(previous), true
+}
+
+func averageDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	return total / time.Duration(len(durations))
+}
+
+// Returns the start of the latest run in which each repository was backed up
+func LastSuccesses(runs []RunResult) map[string]time.Time {
+	successes := make(map[string]time.Time)
+	for _, summary := range SummarizeHistory(runs) {
+		if !summary.LastSuccess.IsZero() {
+			successes[summary.Repo] = summary.LastSuccess
+		}
+	}
+	return successes
+}
+
+// Returns the duration of each repository's latest successful backup, used for ETAs
+func DurationHints(runs []RunResult) map[string]time.Duration {
+	hints := make(map[string]time.Duration)
+	for _, summary := range SummarizeHistory(runs) {
+		if len(summary.Durations) > 0 {
+			hints[summary.Repo] = summary.Durations[len(summary.Durations)-1]
+		}
+	}
+	return hints
+}
@@ -0,0 +1,65 @@
+package functionality
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for idx := range 2 {
+		run := &RunResult{StartTime: start.Add(time.Duration(idx) * time.Hour), Repos: []RepoResult{{Repo: "github.com/org/a", Outcome: OutcomeUpdated}}}
+		if err := AppendHistory(dir, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A run cut short by a crash must not hide the others
+	file, _ := os.OpenFile(filepath.Join(dir, HistoryFile), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"start_time":"2025-01-01T02:00:00Z","repos":[{"re`)
+	file.Close()
+	runs, err := ReadHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || !runs[1].StartTime.Equal(start.Add(time.Hour)) || runs[0].Repos[0].Outcome != OutcomeUpdated {
+		t.Errorf("read back %+v", runs)
+	}
+	if runs, err := ReadHistory(t.TempDir()); err != nil || runs != nil {
+		t.Errorf("missing history gave %v, %v", runs, err)
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var runs []RunResult
+	for idx, duration := range []time.Duration{10, 10, 10, 10, 10, 20, 20, 20, 20, 20} {
+		runs = append(runs, RunResult{StartTime: start.Add(time.Duration(idx) * time.Hour), Repos: []RepoResult{
+			{Repo: "github.com/org/slow", Outcome: OutcomeUpdated, Duration: duration * time.Second},
+		}})
+	}
+	runs = append(runs,
+		RunResult{StartTime: start.Add(20 * time.Hour), Repos: []RepoResult{{Repo: "github.com/org/slow", Outcome: OutcomeFailed, Error: "timeout"}}},
+		RunResult{StartTime: start.Add(21 * time.Hour), Repos: []RepoResult{{Repo: "github.com/org/slow", Outcome: OutcomeSkipped}}},
+		RunResult{StartTime: start.Add(22 * time.Hour), Repos: []RepoResult{{Repo: "github.com/org/slow", Outcome: OutcomeFailed, Error: "reset"}}},
+	)
+	repos := SummarizeHistory(runs)
+	if len(repos) != 1 {
+		t.Fatalf("summarized %d repos, want 1", len(repos))
+	}
+	slow := repos[0]
+	if slow.FailureStreak != 2 || slow.LastError != "reset" || slow.LastOutcome != OutcomeFailed {
+		t.Errorf("streak %d, last error %q, outcome %s; want 2 failures ending with reset", slow.FailureStreak, slow.LastError, slow.LastOutcome)
+	}
+	if !slow.LastSuccess.Equal(start.Add(9 * time.Hour)) {
+		t.Errorf("last success %s", slow.LastSuccess)
+	}
+	if change, ok := slow.Trend(); !ok || change != 1 || slow.AverageDuration() != 20*time.Second {
+		t.Errorf("trend %v (%v), average %s; want +100%% at 20s", change, ok, slow.AverageDuration())
+	}
+	if hints := DurationHints(runs); hints["github.com/org/slow"] != 20*time.Second {
+		t.Errorf("duration hints %v", hints)
+	}
+}
@@ -0,0 +1,172 @@
+package functionality
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// Optional interface for sinks that report task counts, like *utils.Manager
+type statsSink interface {
+	Stats() utils.Stats
+}
+
+// Collects Prometheus metrics from run results and the output manager of the current run
+type Metrics struct {
+	mutex            sync.Mutex
+	runs             int
+	lastRun          *RunResult
+	lastSuccess      map[string]time.Time
+	mirrorSize       map[string]int64
+	bytesFetched     int64
+	errorsByCategory map[ErrorCategory]int
+	sink             statsSink
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		lastSuccess:      make(map[string]time.Time),
+		mirrorSize:       make(map[string]int64),
+		errorsByCategory: make(map[ErrorCategory]int),
+	}
+}
+
+// Reports the task counts of a run's progress sink while it runs; sinks without them are ignored
+func (m *Metrics) TrackSink(sink ProgressSink) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sink, _ = sink.(statsSink)
+}
+
+// Adds the runs of the history, so counters and last successes carry over from
+// earlier processes instead of starting at zero
+func (m *Metrics) Seed(runs []RunResult) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for idx := range runs {
+		m.observe(&runs[idx])
+	}
+}
+
+// Adds a finished run to the metrics
+func (m *Metrics) Observe(result *RunResult) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.observe(result)
+}
+
+// Adds a run; callers hold the lock
+func (m *Metrics) observe(result *RunResult) {
+	m.runs++
+	m.lastRun = result
+	for _, repo := range result.Repos {
+		m.bytesFetched += repo.BytesReceived
+		switch repo.Outcome {
+		case OutcomeCloned, OutcomeUpdated, OutcomeUnchanged:
+			m.lastSuccess[repo.Repo] = repo.EndTime
+			m.mirrorSize[repo.Repo] = repo.MirrorSize
+		case OutcomeFailed:
+			m.errorsByCategory[repo.ErrorCategory]++
+		}
+	}
+}
+
+// Writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var out strings.Builder
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("backhub_runs_total", "counter", "Backup runs recorded in the run history and finished by this process.")
+	fmt.Fprintf(&out, "backhub_runs_total %d\n", m.runs)
+	metric("backhub_bytes_fetched_total", "counter", "Bytes received from remotes by all recorded runs.")
+	fmt.Fprintf(&out, "backhub_bytes_fetched_total %d\n", m.bytesFetched)
+	metric("backhub_errors_total", "counter", "Failed repository backups by error category.")
+	for _, category := range slices.Sorted(maps.Keys(m.errorsByCategory)) {
+		fmt.Fprintf(&out, "backhub_errors_total{category=\"%s\"} %d\n", escapeLabel(string(category)), m.errorsByCategory[category])
+	}
+
+	if m.lastRun != nil {
+		metric("backhub_last_run_timestamp_seconds", "gauge", "Time the last run finished.")
+		fmt.Fprintf(&out, "backhub_last_run_timestamp_seconds %d\n", m.lastRun.EndTime.Unix())
+		metric("backhub_last_run_duration_seconds", "gauge", "Duration of the last run.")
+		fmt.Fprintf(&out, "backhub_last_run_duration_seconds %g\n", m.lastRun.Duration.Seconds())
+		metric("backhub_last_run_repos", "gauge", "Repositories of the last run by outcome.")
+		for _, outcome := range []Outcome{OutcomeCloned, OutcomeUpdated, OutcomeUnchanged, OutcomeFailed, OutcomeSkipped} {
+			fmt.Fprintf(&out, "backhub_last_run_repos{outcome=\"%s\"} %d\n", outcome, m.lastRun.Count(outcome))
+		}
+		var bytes int64
+		for _, repo := range m.lastRun.Repos {
+			bytes += repo.BytesReceived
+		}
+		metric("backhub_last_run_bytes_fetched", "gauge", "Bytes received from remotes by the last run.")
+		fmt.Fprintf(&out, "backhub_last_run_bytes_fetched %d\n", bytes)
+	}
+
+	repos := slices.Sorted(maps.Keys(m.lastSuccess))
+	metric("backhub_repo_last_success_timestamp_seconds", "gauge", "Time the last successful backup of a repository finished.")
+	for _, repo := range repos {
+		fmt.Fprintf(&out, "backhub_repo_last_success_timestamp_seconds{repo=\"%s\"} %d\n", escapeLabel(repo), m.lastSuccess[repo].Unix())
+	}
+	metric("backhub_repo_mirror_size_bytes", "gauge", "Size of a repository's mirror on disk after its last successful backup.")
+	for _, repo := range repos {
+		fmt.Fprintf(&out, "backhub_repo_mirror_size_bytes{repo=\"%s\"} %d\n", escapeLabel(repo), m.mirrorSize[repo])
+	}
+
+	if m.sink != nil {
+		stats := m.sink.Stats()
+		metric("backhub_tasks", "gauge", "Tasks of the current or last run by state.")
+		for _, state := range []struct {
+			name  string
+			count int
+		}{{"queued", stats.Queued}, {"pending", stats.Pending}, {"running", stats.Running}, {"succeeded", stats.Succeeded}, {"failed", stats.Failed}} {
+			fmt.Fprintf(&out, "backhub_tasks{state=\"%s\"} %d\n", state.name, state.count)
+		}
+	}
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+// Serves the metrics to Prometheus scrapes
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Writes the metrics for the node_exporter textfile collector, replacing the
+// file atomically so the collector never reads a partial file
+func (m *Metrics) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := m.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Escapes a label value as the exposition format requires
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,77 @@
+package functionality
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	end := time.Unix(1700000000, 0)
+	metrics := NewMetrics()
+	metrics.Observe(&RunResult{EndTime: end, Duration: 3 * time.Second, Repos: []RepoResult{
+		{Repo: `github.com/org/"quoted"`, Outcome: OutcomeUpdated, EndTime: end, BytesReceived: 2048, MirrorSize: 4096},
+		{Repo: "github.com/org/broken", Outcome: OutcomeFailed, ErrorCategory: ErrorAuth},
+	}})
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"backhub_runs_total 1\n",
+		"backhub_bytes_fetched_total 2048\n",
+		`backhub_errors_total{category="auth"} 1` + "\n",
+		"backhub_last_run_duration_seconds 3\n",
+		`backhub_last_run_repos{outcome="failed"} 1` + "\n",
+		`backhub_repo_last_success_timestamp_seconds{repo="github.com/org/\"quoted\""} 1700000000` + "\n",
+		`backhub_repo_mirror_size_bytes{repo="github.com/org/\"quoted\""} 4096` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "broken") {
+		t.Errorf("failed repo has a last success:\n%s", body)
+	}
+
+	path := filepath.Join(t.TempDir(), "backhub.prom")
+	if err := metrics.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != body {
+		t.Errorf("textfile differs from the served metrics (err %v)", err)
+	}
+}
+
+func TestMetricsSeededFromHistory(t *testing.T) {
+	earlier := time.Unix(1700000000, 0)
+	metrics := NewMetrics()
+	metrics.Seed([]RunResult{
+		{EndTime: earlier, Repos: []RepoResult{
+			{Repo: "github.com/org/a", Outcome: OutcomeCloned, EndTime: earlier, BytesReceived: 1000},
+			{Repo: "github.com/org/b", Outcome: OutcomeCloned, EndTime: earlier, BytesReceived: 500},
+		}},
+	})
+	later := earlier.Add(time.Hour)
+	metrics.Observe(&RunResult{EndTime: later, Repos: []RepoResult{
+		{Repo: "github.com/org/a", Outcome: OutcomeUpdated, EndTime: later, BytesReceived: 24},
+		{Repo: "github.com/org/b", Outcome: OutcomeFailed, ErrorCategory: ErrorNetwork},
+	}})
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"backhub_runs_total 2\n",
+		"backhub_bytes_fetched_total 1524\n",
+		`backhub_repo_last_success_timestamp_seconds{repo="github.com/org/a"} 1700003600` + "\n",
+		`backhub_repo_last_success_timestamp_seconds{repo="github.com/org/b"} 1700000000` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package functionality
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kinds of notification targets
+const (
+	NotifyWebhook = "webhook" // the full payload as JSON
+	NotifySlack   = "slack"   // Slack-compatible incoming webhook
+	NotifyDiscord = "discord" // Discord-compatible webhook
+	NotifyNtfy    = "ntfy"    // ntfy topic URL
+)
+
+// When a notification target fires
+type NotifyTrigger string
+
+const (
+	TriggerAlways  NotifyTrigger = "always"
+	TriggerFailure NotifyTrigger = "failure" // some repository failed
+	TriggerRewrite NotifyTrigger = "rewrite" // some repository's history was rewritten
+)
+
+// Maximum length of a Discord message
+const discordMessageLimit = 2000
+
+// Notification target from the notifications section of the config file
+type NotifyTarget struct {
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url"`
+	On      NotifyTrigger     `yaml:"on"`      // defaults to always
+	Headers map[string]string `yaml:"headers"` // e.g. an Authorization header for ntfy
+}
+
+// Failed repository in a notification
+type NotifyFailure struct {
+	Repo     string        `json:"repo"`
+	Category ErrorCategory `json:"category"`
+	Error    string        `json:"error"`
+}
+
+// Summary of a run sent to notification targets
+type NotifyPayload struct {
+	Status      string          `json:"status"` // success, partial, failed or interrupted
+	Summary     string          `json:"summary"`
+	StartTime   time.Time       `json:"start_time"`
+	Duration    time.Duration   `json:"duration_ns"`
+	Total       int             `json:"total"`
+	Succeeded   int             `json:"succeeded"`
+	Failed      int             `json:"failed"`
+	Skipped     int             `json:"skipped"`
+	Failures    []NotifyFailure `json:"failures,omitempty"`
+	Rewritten   []string        `json:"rewritten,omitempty"` // repositories whose history was rewritten
+	Interrupted bool            `json:"interrupted"`
+}
+
+// Sends run summaries to notification targets
+type Notifier struct {
+	targets []NotifyTarget
+	client  *http.Client
+}
+
+func NewNotifier(targets []NotifyTarget) *Notifier {
+	return &Notifier{targets: targets, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Checks the type, URL and trigger of a target
+func (t NotifyTarget) validate() error {
+	switch t.Type {
+	case NotifyWebhook, NotifySlack, NotifyDiscord, NotifyNtfy:
+	default:
+		return fmt.Errorf("unknown type %q (use webhook, slack, discord or ntfy)", t.Type)
+	}
+	if !strings.HasPrefix(t.URL, "http://") && !strings.HasPrefix(t.URL, "https://") {
+		return fmt.Errorf("url %q is not an http(s) URL", t.URL)
+	}
+	switch t.On {
+	case TriggerAlways, TriggerFailure, TriggerRewrite:
+	default:
+		return fmt.Errorf("unknown trigger %q (use always, failure or rewrite)", t.On)
+	}
+	return nil
+}
+
+// Reports whether a target fires for a run
+func (t NotifyTarget) firesFor(payload NotifyPayload) bool {
+	switch t.On {
+	case TriggerFailure:
+		return payload.Failed > 0
+	case TriggerRewrite:
+		return len(payload.Rewritten) > 0
+	}
+	return true
+}
+
+// Builds the notification payload of a run
+func NewNotifyPayload(result *RunResult) NotifyPayload {
+	payload := NotifyPayload{
+		Status:      result.Status(),
+		StartTime:   result.StartTime,
+		Duration:    result.Duration,
+		Total:       len(result.Repos),
+		Succeeded:   result.Succeeded(),
+		Failed:      result.Count(OutcomeFailed),
+		Skipped:     result.Count(OutcomeSkipped),
+		Interrupted: result.Interrupted,
+	}
+	for _, repo := range result.Repos {
+		if repo.Outcome == OutcomeFailed {
+			payload.Failures = append(payload.Failures, NotifyFailure{Repo: repo.Repo, Category: repo.ErrorCategory, Error: repo.Error})
+		}
+	}
+	for _, repo := range result.Rewritten() {
+		payload.Rewritten = append(payload.Rewritten, repo.Repo)
+	}
+	payload.Summary = fmt.Sprintf("BackHub run %s: %d of %d repositories backed up, %d failed, %d skipped in %s",
+		payload.Status, payload.Succeeded, payload.Total, payload.Failed, payload.Skipped, payload.Duration.Round(time.Second))
+	return payload
+}
+
+// Formats the payload as a chat message, with bold text marked by bold
+func (p NotifyPayload) message(bold string) string {
+	var msg strings.Builder
+	msg.WriteString(bold + p.Summary + bold)
+	if len(p.Failures) > 0 {
+		msg.WriteString("\nFailed:")
+		for _, failure := range p.Failures {
+			fmt.Fprintf(&msg, "\n- %s (%s): %s", failure.Repo, failure.Category, failure.Error)
+		}
+	}
+	if len(p.Rewritten) > 0 {
+		msg.WriteString("\nHistory rewritten:")
+		for _, repo := range p.Rewritten {
+			fmt.Fprintf(&msg, "\n- %s", repo)
+		}
+	}
+	return msg.String()
+}
+
+// Sends the run summary to every target whose trigger matches; returns the joined delivery errors
+func (n *Notifier) Notify(ctx context.Context, result *RunResult) error {
+	payload := NewNotifyPayload(result)
+	var errs []error
+	for _, target := range n.targets {
+		if !target.firesFor(payload) {
+			continue
+		}
+		if err := n.send(ctx, target, payload); err != nil {
+			errs = append(errs, fmt.Errorf("notifying %s target: %w", target.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Delivers a payload in the format of the target
+func (n *Notifier) send(ctx context.Context, target NotifyTarget, payload NotifyPayload) error {
+	var body []byte
+	var err error
+	contentType := "application/json"
+	switch target.Type {
+	case NotifyWebhook:
+		body, err = json.Marshal(payload)
+	case NotifySlack:
+		body, err = json.Marshal(map[string]string{"text": payload.message("*")})
+	case NotifyDiscord:
+		content := []rune(payload.message("**"))
+		if len(content) > discordMessageLimit {
+			content = append(content[:discordMessageLimit-3], []rune("...")...)
+		}
+		body, err = json.Marshal(map[string]string{"content": string(content)})
+	case NotifyNtfy:
+		body, contentType = []byte(payload.message("")), "text/plain; charset=utf-8"
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if target.Type == NotifyNtfy {
+		req.Header.Set("Title", "BackHub run "+payload.Status)
+		req.Header.Set("Tags", "white_check_mark")
+		if payload.Status != "success" {
+			req.Header.Set("Tags", "warning")
+		}
+		if payload.Failed > 0 {
+			req.Header.Set("Priority", "high")
+		}
+	}
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := n.client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err // without the URL, which may hold a secret
+	} else if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("target responded with %s", resp.Status)
+	}
+	return nil
+}
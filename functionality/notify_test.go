@@ -0,0 +1,163 @@
+package functionality
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Request captured by a test receiver
+type received struct {
+	path        string
+	contentType string
+	headers     http.Header
+	body        []byte
+}
+
+// Starts a webhook receiver recording every request; paths starting with /fail get a 500
+func newReceiver(t *testing.T) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mutex sync.Mutex
+	var requests []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, received{r.URL.Path, r.Header.Get("Content-Type"), r.Header, body})
+		mutex.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []received {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]received{}, requests...)
+	}
+}
+
+func failedRunResult() *RunResult {
+	return &RunResult{Duration: 90 * time.Second, Repos: []RepoResult{
+		{Repo: "github.com/org/ok", Outcome: OutcomeUpdated, RefsRewritten: 1},
+		{Repo: "github.com/org/gone", Outcome: OutcomeFailed, ErrorCategory: ErrorNotFound, Error: "repository not found"},
+	}}
+}
+
+func TestNotifyFormats(t *testing.T) {
+	server, requests := newReceiver(t)
+	notifier := NewNotifier([]NotifyTarget{
+		{Type: NotifyWebhook, URL: server.URL + "/webhook", On: TriggerAlways},
+		{Type: NotifySlack, URL: server.URL + "/slack", On: TriggerFailure},
+		{Type: NotifyDiscord, URL: server.URL + "/discord", On: TriggerRewrite},
+		{Type: NotifyNtfy, URL: server.URL + "/ntfy", On: TriggerAlways, Headers: map[string]string{"Authorization": "Bearer tk"}},
+	})
+	if err := notifier.Notify(context.Background(), failedRunResult()); err != nil {
+		t.Fatal(err)
+	}
+	got := requests()
+	if len(got) != 4 {
+		t.Fatalf("received %d requests, want 4", len(got))
+	}
+
+	var payload NotifyPayload
+	if err := json.Unmarshal(got[0].body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Status != "partial" || payload.Failed != 1 || len(payload.Failures) != 1 || payload.Failures[0].Error != "repository not found" || len(payload.Rewritten) != 1 {
+		t.Errorf("unexpected webhook payload %+v", payload)
+	}
+
+	var slack struct{ Text string }
+	json.Unmarshal(got[1].body, &slack)
+	if !strings.HasPrefix(slack.Text, "*BackHub run partial: 1 of 2") || !strings.Contains(slack.Text, "github.com/org/gone (not_found): repository not found") {
+		t.Errorf("unexpected slack text %q", slack.Text)
+	}
+
+	var discord struct{ Content string }
+	json.Unmarshal(got[2].body, &discord)
+	if !strings.HasPrefix(discord.Content, "**BackHub run partial") || !strings.Contains(discord.Content, "History rewritten:\n- github.com/org/ok") {
+		t.Errorf("unexpected discord content %q", discord.Content)
+	}
+
+	ntfy := got[3]
+	if !strings.HasPrefix(ntfy.contentType, "text/plain") || ntfy.headers.Get("Title") != "BackHub run partial" || ntfy.headers.Get("Priority") != "high" || ntfy.headers.Get("Authorization") != "Bearer tk" {
+		t.Errorf("unexpected ntfy request headers %v", ntfy.headers)
+	}
+}
+
+func TestNotifyTriggers(t *testing.T) {
+	server, requests := newReceiver(t)
+	notifier := NewNotifier([]NotifyTarget{
+		{Type: NotifyWebhook, URL: server.URL + "/failure", On: TriggerFailure},
+		{Type: NotifyWebhook, URL: server.URL + "/rewrite", On: TriggerRewrite},
+		{Type: NotifyWebhook, URL: server.URL + "/always", On: TriggerAlways},
+	})
+	clean := &RunResult{Repos: []RepoResult{{Repo: "github.com/org/ok", Outcome: OutcomeUnchanged}}}
+	if err := notifier.Notify(context.Background(), clean); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests(); len(got) != 1 || got[0].path != "/always" {
+		t.Errorf("clean run notified %+v, want only the always target", got)
+	}
+}
+
+func TestNotifyDeliveryError(t *testing.T) {
+	server, _ := newReceiver(t)
+	notifier := NewNotifier([]NotifyTarget{
+		{Type: NotifyDiscord, URL: server.URL + "/fail/secret-token", On: TriggerAlways},
+		{Type: NotifySlack, URL: server.URL + "/slack", On: TriggerAlways},
+	})
+	err := notifier.Notify(context.Background(), failedRunResult())
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("error = %v, want the failed discord delivery", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("error leaks the webhook URL: %v", err)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	t.Setenv("SMTP_PASSWORD", "from-env")
+	cfg, err := ReadConfig(write("repos:\n  - github.com/org/a\nnotifications:\n  - type: ntfy\n    url: https://ntfy.sh/backups\n" +
+		"email:\n  host: smtp.example.com\n  from: backhub@example.com\n  to: [ops@example.com]\n  password: from-file\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Repos) != 1 || len(cfg.Notifications) != 1 || cfg.Notifications[0].On != TriggerAlways {
+		t.Errorf("config = %+v; want one repo and one ntfy target firing always", cfg)
+	}
+	if cfg.Email == nil || cfg.Email.Port != 587 || cfg.Email.Password != "from-env" {
+		t.Errorf("email = %+v; want port 587 and the password from SMTP_PASSWORD", cfg.Email)
+	}
+	for _, invalid := range []string{
+		"notifications:\n  - type: pager\n    url: https://example.com\n",
+		"notifications:\n  - type: slack\n    url: https://example.com\n    on: sometimes\n",
+		"email:\n  host: smtp.example.com\n",
+		"repos:\n  - url: github.com/org/a\n    cron: \"61 * * * *\"\n",
+	} {
+		if _, err := ReadConfig(write(invalid)); err == nil {
+			t.Errorf("accepted invalid config:\n%s", invalid)
+		}
+	}
+	if cfg, err := ReadConfig("github.com/org/a"); err != nil || len(cfg.Repos) != 1 || cfg.Notifications != nil || cfg.Email != nil {
+		t.Errorf("direct repo path gave %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,179 @@
+package functionality
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// Optional interface for sinks that draw progress bars, like *utils.Manager
+type progressBarSink interface {
+	AddProgressBarToStream(name string, percentage float64, text string)
+}
+
+// Matches the progress the server sends over the sideband, such as
+// "Compressing objects:  45% (450/1000)"; go-git prints no client-side phases
+var progressRegex = regexp.MustCompile(`^(?:remote:\s*)?(Counting objects|Compressing objects):\s+(\d+)%\s+\((\d+)/(\d+)\)(,\s+done\.?)?`)
+
+// State of the git phase currently in progress
+type progressPhase struct {
+	name      string
+	percent   float64
+	current   int
+	total     int
+	bytes     int64 // only set while receiving a pack
+	startTime time.Time
+}
+
+// Implements io.Writer to turn git sideband output into per-phase progress bars,
+// and reports the Receiving objects phase of the packs written to the mirror
+type gitProgressWriter struct {
+	taskName    string
+	outputMgr   ProgressSink
+	counter     *byteCounter
+	partial     string // incomplete line carried over to the next write
+	phase       *progressPhase
+	pending     bool // phase has changed since the last update was sent
+	lastUpdate  time.Time
+	minInterval time.Duration
+}
+
+func (h *Handler) newProgressWriter(taskName string) *gitProgressWriter {
+	return &gitProgressWriter{
+		taskName:    taskName,
+		outputMgr:   h.outputMgr,
+		counter:     h.newByteCounter(taskName),
+		lastUpdate:  time.Now(),
+		minInterval: 500 * time.Millisecond,
+	}
+}
+
+// Splits sideband data into lines; git ends in-place progress updates with \r
+func (p *gitProgressWriter) Write(data []byte) (int, error) {
+	text := p.partial + string(data)
+	lines := strings.FieldsFunc(text, func(r rune) bool { return r == '\r' || r == '\n' })
+	p.partial = ""
+	if len(lines) > 0 && !strings.HasSuffix(text, "\r") && !strings.HasSuffix(text, "\n") {
+		p.partial = lines[len(lines)-1]
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		p.handleLine(strings.TrimSpace(line))
+	}
+	return len(data), nil
+}
+
+// Updates the phase from a progress line, or passes any other line through as is
+func (p *gitProgressWriter) handleLine(line string) {
+	if line == "" {
+		return
+	}
+	match := progressRegex.FindStringSubmatch(line)
+	if match == nil {
+		p.outputMgr.AddStreamLine(p.taskName, line)
+		return
+	}
+	if p.phase == nil || p.phase.name != match[1] {
+		p.phase = &progressPhase{name: match[1], startTime: time.Now()}
+	}
+	p.phase.percent, _ = strconv.ParseFloat(match[2], 64)
+	p.phase.current, _ = strconv.Atoi(match[3])
+	p.phase.total, _ = strconv.Atoi(match[4])
+	p.pending = true
+	if match[5] != "" { // phase finished, always show its final state
+		p.sendPhase()
+		p.outputMgr.AddStreamLine(p.taskName, fmt.Sprintf("%s: %d/%d done", p.phase.name, p.phase.current, p.phase.total))
+		p.phase = nil
+		return
+	}
+	if time.Since(p.lastUpdate) >= p.minInterval {
+		p.sendPhase()
+	}
+}
+
+// Sends the current phase as a progress bar, or as a plain line to sinks without bars
+func (p *gitProgressWriter) sendPhase() {
+	p.pending = false
+	p.lastUpdate = time.Now()
+	text := fmt.Sprintf("%s %d/%d", p.phase.name, p.phase.current, p.phase.total)
+	if p.phase.bytes > 0 {
+		text += fmt.Sprintf(" | %s | %s/s", utils.FormatBytes(float64(p.phase.bytes)), utils.FormatBytes(p.phase.throughput()))
+	}
+	if eta := p.phase.eta(); eta > 0 {
+		text += fmt.Sprintf(" | ETA %s", eta)
+	}
+	if bars, ok := p.outputMgr.(progressBarSink); ok {
+		bars.AddProgressBarToStream(p.taskName, p.phase.percent, text)
+		return
+	}
+	p.outputMgr.UpdateStreamOutput(p.taskName, []string{fmt.Sprintf("%.0f%% %s", p.phase.percent, text)})
+}
+
+// Sends the last update held back by the rate limit; called once git is done
+func (p *gitProgressWriter) Flush() {
+	if p.partial != "" {
+		p.handleLine(strings.TrimSpace(p.partial))
+		p.partial = ""
+	}
+	if p.pending && p.phase != nil {
+		p.sendPhase()
+	}
+}
+
+// Starts the Receiving objects phase of a pack
+func (p *gitProgressWriter) startReceiving() {
+	p.phase = &progressPhase{name: "Receiving objects", startTime: time.Now()}
+}
+
+// Records n more bytes of the pack, which has bytes so far and holds total objects
+func (p *gitProgressWriter) received(n int, bytes, objects, total int64) {
+	p.counter.add(n)
+	p.updateReceiving(bytes, objects, total)
+	p.pending = true
+	if time.Since(p.lastUpdate) >= p.minInterval {
+		p.sendPhase()
+	}
+}
+
+// Shows the final state of the pack once it is fully written
+func (p *gitProgressWriter) finishReceiving(bytes, objects, total int64) {
+	p.updateReceiving(bytes, objects, total)
+	p.sendPhase()
+	p.outputMgr.AddStreamLine(p.taskName, fmt.Sprintf("%s: %d/%d done, %s at %s/s", p.phase.name, objects, total,
+		utils.FormatBytes(float64(bytes)), utils.FormatBytes(p.phase.throughput())))
+	p.phase = nil
+}
+
+func (p *gitProgressWriter) updateReceiving(bytes, objects, total int64) {
+	if p.phase == nil || p.phase.name != "Receiving objects" { // a sideband line came in between
+		p.startReceiving()
+	}
+	p.phase.bytes = bytes
+	p.phase.current, p.phase.total = int(objects), int(total)
+	if total > 0 {
+		p.phase.percent = float64(objects) * 100 / float64(total)
+	}
+}
+
+// Bytes received per second since the phase started
+func (ph *progressPhase) throughput() float64 {
+	elapsed := time.Since(ph.startTime).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(ph.bytes) / elapsed
+}
+
+// Estimates the remaining time from the progress made since the phase started
+func (ph *progressPhase) eta() time.Duration {
+	if ph.current <= 0 || ph.total <= ph.current {
+		return 0
+	}
+	elapsed := time.Since(ph.startTime)
+	remaining := time.Duration(float64(elapsed) * float64(ph.total-ph.current) / float64(ph.current))
+	return remaining.Round(time.Second)
+}
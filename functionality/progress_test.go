@@ -0,0 +1,63 @@
+package functionality
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// Records what the progress writer sends, with bars drawn as "bar" lines
+type recordingSink struct {
+	lines []string
+	bars  []string
+}
+
+func (s *recordingSink) Register(name string)               {}
+func (s *recordingSink) SetMessage(name, message string)    {}
+func (s *recordingSink) SetStatus(name, status string)      {}
+func (s *recordingSink) Complete(name string)               {}
+func (s *recordingSink) ReportError(name string, err error) {}
+func (s *recordingSink) AddStreamLine(name, line string)    { s.lines = append(s.lines, line) }
+func (s *recordingSink) UpdateStreamOutput(name string, output []string) {
+	s.lines = append(s.lines, output...)
+}
+func (s *recordingSink) AddProgressBarToStream(name string, percentage float64, text string) {
+	s.bars = append(s.bars, fmt.Sprintf("%.0f%% %s", percentage, text))
+}
+
+// Sideband output of a GitHub fetch as go-git hands it to the progress writer
+const sidebandOutput = "Enumerating objects: 12, done.\n" +
+	"Counting objects:   8% (1/12)\rCounting objects: 100% (12/12)\rCounting objects: 100% (12/12), done.\n" +
+	"Compressing objects:  14% (1/7)\rCompressing objects: 100% (7/7)\rCompressing objects: 100% (7/7), done.\n" +
+	"Total 12 (delta 2), reused 10 (delta 1), pack-reused 0\n"
+
+func TestProgressWriterSideband(t *testing.T) {
+	for _, chunk := range []int{len(sidebandOutput), 7, 1} {
+		t.Run(fmt.Sprintf("chunks of %d", chunk), func(t *testing.T) {
+			sink := &recordingSink{}
+			writer := &gitProgressWriter{taskName: "repo", outputMgr: sink}
+			for start := 0; start < len(sidebandOutput); start += chunk {
+				end := min(start+chunk, len(sidebandOutput))
+				if n, err := writer.Write([]byte(sidebandOutput[start:end])); err != nil || n != end-start {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			writer.Flush()
+
+			wantLines := []string{
+				"Enumerating objects: 12, done.",
+				"Counting objects: 12/12 done",
+				"Compressing objects: 7/7 done",
+				"Total 12 (delta 2), reused 10 (delta 1), pack-reused 0",
+			}
+			if !slices.Equal(sink.lines, wantLines) {
+				t.Errorf("lines = %q, want %q", sink.lines, wantLines)
+			}
+			for _, bar := range []string{"100% Counting objects 12/12", "100% Compressing objects 7/7"} {
+				if !slices.Contains(sink.bars, bar) {
+					t.Errorf("bars = %q, missing %q", sink.bars, bar)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package functionality
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// Outcome of backing up a single repository
+type Outcome string
+
+const (
+	OutcomeCloned    Outcome = "cloned"
+	OutcomeUpdated   Outcome = "updated"
+	OutcomeUnchanged Outcome = "unchanged"
+	OutcomeFailed    Outcome = "failed"
+	OutcomeSkipped   Outcome = "skipped"
+)
+
+// Result of backing up a single repository
+type RepoResult struct {
+	Repo          string        `json:"repo"`
+	Outcome       Outcome       `json:"outcome"`
+	Error         string        `json:"error,omitempty"`
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+	StartTime     time.Time     `json:"start_time"`
+	EndTime       time.Time     `json:"end_time"`
+	Duration      time.Duration `json:"duration_ns"`
+	RefsChanged   int           `json:"refs_changed"`   // refs created by a clone, or added/moved/removed by a fetch
+	RefsRewritten int           `json:"refs_rewritten"` // refs a fetch moved to a commit not descending from the old one
+	BytesReceived int64         `json:"bytes_received"` // read from the remote over HTTP(S), including retries
+	MirrorSize    int64         `json:"mirror_size"`    // size of the mirror on disk after the backup
+}
+
+// Result of a whole backup run, with repos in dispatch order
+type RunResult struct {
+	StartTime   time.Time     `json:"start_time"`
+	EndTime     time.Time     `json:"end_time"`
+	Duration    time.Duration `json:"duration_ns"`
+	Interrupted bool          `json:"interrupted"`
+	Repos       []RepoResult  `json:"repos"`
+}
+
+// Counts the repositories that ended with the given outcome
+func (r *RunResult) Count(outcome Outcome) int {
+	count := 0
+	for _, repo := range r.Repos {
+		if repo.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// Counts the repositories that were backed up or already current
+func (r *RunResult) Succeeded() int {
+	return r.Count(OutcomeCloned) + r.Count(OutcomeUpdated) + r.Count(OutcomeUnchanged)
+}
+
+// Describes the run as success, partial, failed or interrupted
+func (r *RunResult) Status() string {
+	switch succeeded := r.Succeeded(); {
+	case r.Interrupted:
+		return "interrupted"
+	case succeeded == len(r.Repos):
+		return "success"
+	case succeeded == 0:
+		return "failed"
+	}
+	return "partial"
+}
+
+// Returns the repositories whose history was rewritten on the remote
+func (r *RunResult) Rewritten() []RepoResult {
+	var rewritten []RepoResult
+	for _, repo := range r.Repos {
+		if repo.RefsRewritten > 0 {
+			rewritten = append(rewritten, repo)
+		}
+	}
+	return rewritten
+}
+
+// Writes the result as indented JSON
+func (r *RunResult) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Columns of the per-repository results table
+var resultHeaders = []string{"Repository", "Action", "Refs Changed", "Received", "Mirror Size", "Duration"}
+
+// Returns one results table row per repository
+func (r *RunResult) TableRows() [][]string {
+	rows := make([][]string, 0, len(r.Repos))
+	for _, repo := range r.Repos {
+		row := []string{repo.Repo, string(repo.Outcome), "-", "-", "-", repo.Duration.Round(time.Millisecond).String()}
+		if repo.Outcome != OutcomeFailed && repo.Outcome != OutcomeSkipped {
+			row[2] = fmt.Sprintf("%d", repo.RefsChanged)
+			row[3] = utils.FormatBytes(float64(repo.BytesReceived))
+			row[4] = utils.FormatBytes(float64(repo.MirrorSize))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Writes the results table as CSV for a .csv path and as markdown otherwise
+func (r *RunResult) WriteTable(path string) error {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer := csv.NewWriter(file)
+		writer.Write(resultHeaders)
+		writer.WriteAll(r.TableRows())
+		return writer.Error()
+	}
+	table := utils.NewTable(resultHeaders)
+	table.Rows = r.TableRows()
+	return table.WriteMarkdownTableToFile(path)
+}
@@ -0,0 +1,118 @@
+package functionality
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTableResult() *RunResult {
+	return &RunResult{Repos: []RepoResult{
+		{Repo: "github.com/org/a", Outcome: OutcomeCloned, RefsChanged: 12, BytesReceived: 3 << 20, MirrorSize: 5 << 20, Duration: 2500 * time.Millisecond},
+		{Repo: "github.com/org/b", Outcome: OutcomeUnchanged, BytesReceived: 1536, MirrorSize: 1 << 20, Duration: 300 * time.Millisecond},
+		{Repo: "github.com/org/c", Outcome: OutcomeFailed, BytesReceived: 512, Duration: time.Second},
+	}}
+}
+
+func TestTableRows(t *testing.T) {
+	want := [][]string{
+		{"github.com/org/a", "cloned", "12", "3.00 MiB", "5.00 MiB", "2.5s"},
+		{"github.com/org/b", "unchanged", "0", "1.50 KiB", "1.00 MiB", "300ms"},
+		{"github.com/org/c", "failed", "-", "-", "-", "1s"},
+	}
+	rows := newTableResult().TableRows()
+	if !slices.EqualFunc(rows, want, slices.Equal) {
+		t.Errorf("TableRows() = %q, want %q", rows, want)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	dir := t.TempDir()
+	result := newTableResult()
+
+	csvPath := filepath.Join(dir, "results.csv")
+	if err := result.WriteTable(csvPath); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || !slices.Equal(records[0], resultHeaders) || records[1][3] != "3.00 MiB" {
+		t.Errorf("CSV = %q, want headers and a row per repository", records)
+	}
+
+	mdPath := filepath.Join(dir, "results.md")
+	if err := result.WriteTable(mdPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(mdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range []string{"Received", "3.00 MiB", "1.50 KiB"} {
+		if !strings.Contains(string(data), cell) {
+			t.Errorf("markdown table is missing %q:\n%s", cell, data)
+		}
+	}
+}
+
+func TestRunResultStatus(t *testing.T) {
+	repos := func(outcomes ...Outcome) []RepoResult {
+		var result []RepoResult
+		for _, outcome := range outcomes {
+			result = append(result, RepoResult{Outcome: outcome})
+		}
+		return result
+	}
+	tests := []struct {
+		result RunResult
+		want   string
+	}{
+		{RunResult{Repos: repos(OutcomeCloned, OutcomeUpdated, OutcomeUnchanged)}, "success"},
+		{RunResult{Repos: repos(OutcomeCloned, OutcomeFailed)}, "partial"},
+		{RunResult{Repos: repos(OutcomeUpdated, OutcomeSkipped)}, "partial"},
+		{RunResult{Repos: repos(OutcomeFailed, OutcomeSkipped)}, "failed"},
+		{RunResult{Repos: repos(OutcomeCloned, OutcomeSkipped), Interrupted: true}, "interrupted"},
+	}
+	for _, test := range tests {
+		if got := test.result.Status(); got != test.want {
+			t.Errorf("Status() of %+v = %q, want %q", test.result.Repos, got, test.want)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	result := newTableResult()
+	result.Repos[2].ErrorCategory = ErrorAuth
+	if err := result.WriteJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded RunResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Repos) != 3 || decoded.Repos[0].BytesReceived != 3<<20 || decoded.Repos[2].ErrorCategory != ErrorAuth {
+		t.Errorf("decoded %+v, want the written repositories", decoded.Repos)
+	}
+	for _, key := range []string{`"outcome": "cloned"`, `"error_category": "auth"`, `"duration_ns": 2500000000`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("report lacks %s:\n%s", key, data)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package functionality
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Upper bound for the delay between two attempts
+const maxRetryDelay = time.Minute
+
+// Runs an operation, retrying transient failures with exponential backoff and jitter
+func (h *Handler) withRetry(ctx context.Context, taskName, operation string, fn func() error) error {
+	attempts := h.retries + 1
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Attempt %d/%d: %s", attempt, attempts, operation))
+		}
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		category := ClassifyError(err)
+		if !category.Transient() || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= attempts {
+			h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Giving up %s after %d attempts", operation, attempt))
+			return err
+		}
+		delay := h.retryDelay(attempt)
+		h.outputMgr.AddStreamLine(taskName, fmt.Sprintf("Attempt %d/%d failed with %s error, retrying in %s", attempt, attempts, category, delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Doubles the base delay per attempt, caps it and keeps a random half as jitter
+func (h *Handler) retryDelay(attempt int) time.Duration {
+	if h.retryBaseDelay <= 0 {
+		return 0
+	}
+	delay := h.retryBaseDelay << min(attempt-1, 30)
+	if delay <= 0 || delay > maxRetryDelay { // shift overflow or above the cap
+		delay = maxRetryDelay
+	}
+	half := delay / 2
+	return (half + rand.N(half+1)).Round(time.Millisecond)
+}
@@ -0,0 +1,67 @@
+package functionality
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/tanq16/backhub/utils"
+)
+
+func TestRetryDelay(t *testing.T) {
+	handler := NewHandler("")
+	handler.SetRetryPolicy(10, 2*time.Second)
+	for attempt, full := range map[int]time.Duration{
+		1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 6: maxRetryDelay, 40: maxRetryDelay, 100: maxRetryDelay,
+	} {
+		for range 50 {
+			// Jitter keeps at least half of the doubled delay
+			if delay := handler.retryDelay(attempt); delay < full/2 || delay > full {
+				t.Fatalf("attempt %d waited %s, want %s to %s", attempt, delay, full/2, full)
+			}
+		}
+	}
+	handler.SetRetryPolicy(2, 0)
+	if delay := handler.retryDelay(3); delay != 0 {
+		t.Errorf("zero base delay waited %s", delay)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	handler := NewHandler("")
+	handler.SetProgressSink(utils.NewManager(15, utils.WithWriter(io.Discard)))
+	handler.SetRetryPolicy(2, time.Millisecond)
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"transient", syscall.ECONNRESET, 3},
+		{"permanent", transport.ErrAuthenticationRequired, 1},
+	}
+	for _, test := range tests {
+		attempts := 0
+		err := handler.withRetry(context.Background(), "task", "fetch", func() error {
+			attempts++
+			return test.err
+		})
+		if !errors.Is(err, test.err) || attempts != test.attempts {
+			t.Errorf("%s: %d attempts ending in %v, want %d", test.name, attempts, err, test.attempts)
+		}
+	}
+
+	attempts := 0
+	err := handler.withRetry(context.Background(), "task", "fetch", func() error {
+		if attempts++; attempts < 2 {
+			return syscall.ECONNRESET
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("recovering fetch: %d attempts ending in %v", attempts, err)
+	}
+}
@@ -0,0 +1,100 @@
+package functionality
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Outcome of a status check
+type StatusResult struct {
+	Counts      map[string]int // repositories per drift state
+	Total       int
+	Interrupted bool
+}
+
+// Counts the repositories whose mirror matches the remote
+func (r *StatusResult) UpToDate() int {
+	return r.Counts[driftUpToDate]
+}
+
+// Counts the repositories whose remote could not be checked
+func (r *StatusResult) Unreachable() int {
+	return r.Counts[driftUnreachable]
+}
+
+// Reports drift between local mirrors and their remotes without fetching objects
+func (h *Handler) ExecuteStatus(ctx context.Context) *StatusResult {
+	result := &StatusResult{Counts: make(map[string]int), Total: len(h.repos)}
+	countsMutex := &sync.Mutex{}
+
+	h.outputMgr.SetMessage("logistics", fmt.Sprintf("Checking %d repositories", len(h.repos)))
+	h.dispatch(len(h.repos), func(idx int) {
+		if ctx.Err() != nil {
+			return
+		}
+		repo := h.repos[idx].URL
+		taskName := fmt.Sprintf("repo-%s", repo)
+		h.outputMgr.Register(taskName)
+		h.outputMgr.SetMessage(taskName, fmt.Sprintf("Listing remote refs for %s", repo))
+		drift, err := h.checkDrift(ctx, repo)
+		countsMutex.Lock()
+		result.Counts[drift.state]++
+		countsMutex.Unlock()
+		if err != nil {
+			h.outputMgr.ReportError(taskName, err)
+			return
+		}
+		h.reportDrift(taskName, repo, drift)
+	})
+	result.Interrupted = ctx.Err() != nil
+
+	// Final summary
+	summary := fmt.Sprintf("%d up to date, %d behind, %d ahead or rewritten, %d unreachable, %d corrupt, %d not mirrored",
+		result.Counts[driftUpToDate], result.Counts[driftBehind], result.Counts[driftAheadOrRewritten], result.Counts[driftUnreachable], result.Counts[driftCorrupt], result.Counts[driftNotMirrored])
+	if result.Interrupted {
+		h.outputMgr.SetMessage("logistics", fmt.Sprintf("Status check interrupted: %s", summary))
+		h.outputMgr.Complete("logistics")
+		h.outputMgr.SetStatus("logistics", "warning")
+	} else {
+		h.outputMgr.SetMessage("logistics", fmt.Sprintf("Status check completed: %s", summary))
+		h.outputMgr.Complete("logistics")
+	}
+	h.stopDisplay()
+	return result
+}
+
+// Displays the drift state of a single repository
+func (h *Handler) reportDrift(taskName, repo string, drift refDrift) {
+	message := fmt.Sprintf("%s is %s", repo, drift.state)
+	if drift.state == driftNotMirrored {
+		h.outputMgr.AddStreamLine(taskName, "No local mirror found")
+	} else {
+		details := fmt.Sprintf("%d refs behind, %d refs rewritten, %d refs only in mirror", drift.behind, drift.rewritten, drift.localOnly)
+		h.outputMgr.AddStreamLine(taskName, details)
+		if drift.state != driftUpToDate {
+			message = fmt.Sprintf("%s (%s)", message, details)
+		}
+	}
+	h.outputMgr.SetMessage(taskName, message)
+	h.outputMgr.Complete(taskName)
+	if drift.state != driftUpToDate {
+		h.outputMgr.SetStatus(taskName, "warning")
+	}
+}
+
+// Entry point to run the status check
+func (h *Handler) RunStatus(ctx context.Context, configPath string) (*StatusResult, error) {
+	h.Setup()
+	if err := h.ValidateToken(); err != nil {
+		h.outputMgr.ReportError("logistics", err)
+		h.stopDisplay()
+		return nil, err
+	}
+	if err := h.LoadConfig(configPath); err != nil {
+		h.outputMgr.ReportError("logistics", err)
+		h.stopDisplay()
+		return nil, err
+	}
+	return h.ExecuteStatus(ctx), nil
+}
@@ -0,0 +1,49 @@
+package functionality
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/tanq16/backhub/utils"
+)
+
+// Creates a handler for repos whose mirrors would live in an empty directory
+func newStatusHandler(t *testing.T, repos ...string) *Handler {
+	handler := NewHandler("")
+	handler.SetProgressSink(utils.NewManager(15, utils.WithWriter(io.Discard)))
+	handler.SetCloneFolder(t.TempDir())
+	handler.SetConcurrency(2)
+	for _, repo := range repos {
+		handler.repos = append(handler.repos, RepoEntry{URL: repo})
+	}
+	handler.Setup()
+	return handler
+}
+
+func TestExecuteStatus(t *testing.T) {
+	repos := []string{"github.com/org/a", "github.com/org/b", "github.com/org/c"}
+	// Missing mirrors are reported without contacting the remote
+	result := newStatusHandler(t, repos...).ExecuteStatus(context.Background())
+	if result.Total != 3 || result.Counts[driftNotMirrored] != 3 || result.UpToDate() != 0 || result.Interrupted {
+		t.Errorf("status %+v, want 3 repositories not mirrored", result)
+	}
+
+	// A mirror that cannot be opened is corrupt, not unreachable
+	handler := newStatusHandler(t, repos...)
+	if err := os.Mkdir(handler.getLocalFolderName(repos[0]), 0755); err != nil {
+		t.Fatal(err)
+	}
+	result = handler.ExecuteStatus(context.Background())
+	if result.Counts[driftCorrupt] != 1 || result.Unreachable() != 0 || result.Counts[driftNotMirrored] != 2 {
+		t.Errorf("status %+v, want the broken mirror reported as corrupt", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = newStatusHandler(t, repos...).ExecuteStatus(ctx)
+	if !result.Interrupted || len(result.Counts) != 0 {
+		t.Errorf("cancelled status %+v, want interrupted without checks", result)
+	}
+}
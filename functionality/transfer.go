@@ -0,0 +1,121 @@
+package functionality
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// go-git only relays the server's progress messages, not the client-side
+// "Receiving objects" phase, so packs are counted as they are written to the mirror
+
+// Bytes received for one repository, across all its fetches and retries
+type byteCounter struct {
+	total    atomic.Int64
+	onUpdate func(total int64) // called after every write, may be nil
+}
+
+func (c *byteCounter) add(n int) {
+	total := c.total.Add(int64(n))
+	if c.onUpdate != nil {
+		c.onUpdate(total)
+	}
+}
+
+// Creates the counter of a repository, reporting its bytes to sinks with an overall line
+func (h *Handler) newByteCounter(taskName string) *byteCounter {
+	counter := &byteCounter{}
+	if overall, ok := h.outputMgr.(overallSink); ok {
+		counter.onUpdate = func(total int64) { overall.SetBytesReceived(taskName, total) }
+	}
+	return counter
+}
+
+// Object storage of a bare mirror that reports the packs written to it
+type receivingStorage struct {
+	*filesystem.Storage
+	progress *gitProgressWriter
+}
+
+// Opens the storage of the bare mirror in folderName, reporting received packs to progress
+func newReceivingStorage(folderName string, progress *gitProgressWriter) *receivingStorage {
+	storage := filesystem.NewStorage(osfs.New(folderName), cache.NewObjectLRUDefault())
+	return &receivingStorage{Storage: storage, progress: progress}
+}
+
+// Used by go-git to stream a fetched pack into the mirror
+func (s *receivingStorage) PackfileWriter() (io.WriteCloser, error) {
+	w, err := s.Storage.PackfileWriter()
+	if err != nil {
+		return nil, err
+	}
+	return newPackReceiver(w, s.progress), nil
+}
+
+// Opens an existing mirror through a receiving storage
+func openMirror(folderName string, progress *gitProgressWriter) (*git.Repository, error) {
+	return git.Open(newReceivingStorage(folderName, progress), nil)
+}
+
+// Writes a pack to the mirror while a scanner counts the objects it holds
+type packReceiver struct {
+	io.WriteCloser
+	progress *gitProgressWriter
+	pipe     *io.PipeWriter
+	done     chan struct{}
+	bytes    int64
+	objects  atomic.Int64
+	total    atomic.Int64
+}
+
+func newPackReceiver(w io.WriteCloser, progress *gitProgressWriter) *packReceiver {
+	reader, writer := io.Pipe()
+	r := &packReceiver{WriteCloser: w, progress: progress, pipe: writer, done: make(chan struct{})}
+	progress.startReceiving()
+	go r.scan(reader)
+	return r
+}
+
+// Counts the objects of the pack, draining the rest if it cannot be parsed
+func (r *packReceiver) scan(reader *io.PipeReader) {
+	defer close(r.done)
+	defer io.Copy(io.Discard, reader)
+	scanner := packfile.NewScanner(reader)
+	_, objects, err := scanner.Header()
+	if err != nil {
+		return
+	}
+	r.total.Store(int64(objects))
+	for range objects {
+		if _, err := scanner.NextObjectHeader(); err != nil {
+			return
+		}
+		if _, _, err := scanner.NextObject(io.Discard); err != nil {
+			return
+		}
+		r.objects.Add(1)
+	}
+}
+
+func (r *packReceiver) Write(p []byte) (int, error) {
+	n, err := r.WriteCloser.Write(p)
+	if n > 0 {
+		r.pipe.Write(p[:n])
+		r.bytes += int64(n)
+		r.progress.received(n, r.bytes, r.objects.Load(), r.total.Load())
+	}
+	return n, err
+}
+
+// Waits for the scanner so the final object count is reported, then closes the pack
+func (r *packReceiver) Close() error {
+	r.pipe.Close()
+	<-r.done
+	r.progress.finishReceiving(r.bytes, r.objects.Load(), r.total.Load())
+	return r.WriteCloser.Close()
+}
@@ -0,0 +1,149 @@
+package functionality
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/tanq16/backhub/utils"
+)
+
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+// Serves a smart HTTP ref advertisement with a single branch
+func newRefsServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	hash := strings.Repeat("1", 40)
+	body := pktLine("# service=git-upload-pack\n") + "0000" +
+		pktLine(hash+" HEAD\x00symref=HEAD:refs/heads/main side-band-64k ofs-delta\n") +
+		pktLine(hash+" refs/heads/main\n") + "0000"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, body
+}
+
+// Encodes a pack holding count small blobs
+func encodePack(t *testing.T, count int) []byte {
+	t.Helper()
+	storage := memory.NewStorage()
+	var hashes []plumbing.Hash
+	for i := range count {
+		blob := storage.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, _ := blob.Writer()
+		fmt.Fprintf(w, "blob %d %s", i, strings.Repeat("x", i*100))
+		w.Close()
+		hash, err := storage.SetEncodedObject(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	var pack bytes.Buffer
+	if _, err := packfile.NewEncoder(&pack, storage, false).Encode(hashes, 10); err != nil {
+		t.Fatal(err)
+	}
+	return pack.Bytes()
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Records the bytes reported for the overall line
+type overallRecorder struct {
+	recordingSink
+	bytes map[string]int64
+}
+
+func (r *overallRecorder) TrackOverall(name string, total int)                     {}
+func (r *overallRecorder) SetExpectedDuration(name string, expected time.Duration) {}
+func (r *overallRecorder) SetBytesReceived(name string, bytes int64)               { r.bytes[name] = bytes }
+
+func TestPackReceiver(t *testing.T) {
+	pack := encodePack(t, 20)
+	sink := &overallRecorder{bytes: map[string]int64{}}
+	handler := NewHandler("")
+	handler.SetProgressSink(sink)
+	progress := handler.newProgressWriter("repo-a")
+	progress.minInterval = 0
+
+	var written bytes.Buffer
+	receiver := newPackReceiver(nopWriteCloser{&written}, progress)
+	for start := 0; start < len(pack); start += 64 {
+		if _, err := receiver.Write(pack[start:min(start+64, len(pack))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := receiver.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(written.Bytes(), pack) {
+		t.Error("pack written to the mirror differs from the one received")
+	}
+	if got := progress.counter.total.Load(); got != int64(len(pack)) || sink.bytes["repo-a"] != got {
+		t.Errorf("counted %d bytes and reported %d, want %d", got, sink.bytes["repo-a"], len(pack))
+	}
+	last := sink.bars[len(sink.bars)-1]
+	if !strings.HasPrefix(last, "100% Receiving objects 20/20 | ") || !strings.Contains(last, "/s") {
+		t.Errorf("last bar = %q, want the full Receiving phase with throughput", last)
+	}
+	done := fmt.Sprintf("Receiving objects: 20/20 done, %s at ", utils.FormatBytes(float64(len(pack))))
+	if !slices.ContainsFunc(sink.lines, func(line string) bool { return strings.HasPrefix(line, done) }) {
+		t.Errorf("lines = %q, missing %q", sink.lines, done)
+	}
+}
+
+func TestCloneCountsReceivedPack(t *testing.T) {
+	source, commit := newTestRepo(t)
+	commit("first")
+	worktree, _ := source.Worktree()
+	handler := NewHandler("")
+	sink := &recordingSink{}
+	handler.SetProgressSink(sink)
+	progress := handler.newProgressWriter("repo")
+
+	mirror := filepath.Join(t.TempDir(), "repo.git")
+	if err := handler.cloneRepo(context.Background(), worktree.Filesystem.Root(), mirror, nil, "repo", progress, &RepoResult{}); err != nil {
+		t.Fatal(err)
+	}
+	if progress.counter.total.Load() == 0 {
+		t.Error("no pack bytes counted for the clone")
+	}
+	cloned := progress.counter.total.Load()
+	commit("second")
+	outcome, err := handler.updateRepo(context.Background(), worktree.Filesystem.Root(), mirror, nil, "repo", progress, &RepoResult{})
+	if err != nil || outcome != OutcomeUpdated {
+		t.Fatalf("update = %v, %v", outcome, err)
+	}
+	if progress.counter.total.Load() <= cloned {
+		t.Error("no pack bytes counted for the fetch")
+	}
+	if !slices.ContainsFunc(sink.lines, func(line string) bool { return strings.HasPrefix(line, "Receiving objects: ") }) {
+		t.Errorf("lines = %q, missing the Receiving phase", sink.lines)
+	}
+	// Byte counting must leave go-git's protocol clients alone, since their
+	// proxy and custom CA support needs the default HTTP transport
+	if client.Protocols["https"] != githttp.DefaultClient {
+		t.Error("the https protocol client was replaced")
+	}
+}
@@ -0,0 +1,119 @@
+// Package backhub exposes BackHub's mirror backups as a Go library. It runs
+// without taking over the terminal; progress is reported through an optional
+// event sink and every run returns a typed result.
+package backhub
+
+import (
+	"context"
+	"time"
+
+	"github.com/tanq16/backhub/functionality"
+)
+
+// Repository to back up, as found in the YAML config
+type RepoSpec = functionality.RepoEntry
+
+// Result types returned by Backup
+type (
+	Result        = functionality.RunResult
+	RepoResult    = functionality.RepoResult
+	Outcome       = functionality.Outcome
+	ErrorCategory = functionality.ErrorCategory
+)
+
+const (
+	OutcomeCloned    = functionality.OutcomeCloned
+	OutcomeUpdated   = functionality.OutcomeUpdated
+	OutcomeUnchanged = functionality.OutcomeUnchanged
+	OutcomeFailed    = functionality.OutcomeFailed
+	OutcomeSkipped   = functionality.OutcomeSkipped
+)
+
+type options struct {
+	token       string
+	concurrency int
+	retries     int
+	retryDelay  time.Duration
+	timeout     time.Duration
+	destination string
+	progress    functionality.ProgressSink
+	hints       map[string]time.Duration
+}
+
+// Configures a call to Backup
+type Option func(*options)
+
+// Sets the GitHub token used for HTTPS authentication
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// Sets the number of repositories backed up in parallel (default 5)
+func WithConcurrency(concurrency int) Option {
+	return func(o *options) { o.concurrency = concurrency }
+}
+
+// Sets retries for transient errors and the initial backoff (default 2 and 2s)
+func WithRetries(retries int, baseDelay time.Duration) Option {
+	return func(o *options) {
+		o.retries = retries
+		o.retryDelay = baseDelay
+	}
+}
+
+// Sets the per-repository timeout, 0 disables it (default 30m)
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// Sets the directory in which mirrors are created (default current directory)
+func WithDestination(dir string) Option {
+	return func(o *options) { o.destination = dir }
+}
+
+// Sets the expected duration of repositories, such as their last backup, for ETAs
+func WithDurationHints(hints map[string]time.Duration) Option {
+	return func(o *options) { o.hints = hints }
+}
+
+// Sends progress as typed events to the sink
+func WithEventSink(sink EventSink) Option {
+	return func(o *options) { o.progress = &eventAdapter{sink: sink} }
+}
+
+// Sends progress to a task-based sink such as *utils.Manager, which then also draws its display
+func WithProgressSink(sink functionality.ProgressSink) Option {
+	return func(o *options) { o.progress = sink }
+}
+
+// Mirrors the given repositories and returns the outcome of each one
+func Backup(ctx context.Context, repos []RepoSpec, opts ...Option) (*Result, error) {
+	o := &options{
+		concurrency: 5,
+		retries:     2,
+		retryDelay:  2 * time.Second,
+		timeout:     30 * time.Minute,
+		destination: ".",
+		progress:    &eventAdapter{sink: EventSinkFunc(func(Event) {})},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	handler := functionality.NewHandler(o.token)
+	handler.SetProgressSink(o.progress)
+	handler.SetConcurrency(o.concurrency)
+	handler.SetRetryPolicy(o.retries, o.retryDelay)
+	handler.SetRepoTimeout(o.timeout)
+	handler.SetCloneFolder(o.destination)
+	handler.SetDurationHints(o.hints)
+	return handler.RunBackupRepos(ctx, repos)
+}
+
+// Reads repository specs from a YAML config file or a direct github.com/owner/repo path
+func LoadRepoSpecs(path string) ([]RepoSpec, error) {
+	cfg, err := functionality.ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Repos, nil
+}
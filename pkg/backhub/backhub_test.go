@@ -0,0 +1,40 @@
+package backhub
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestBackupCancelled(t *testing.T) {
+	var mutex sync.Mutex
+	var events []Event
+	sink := EventSinkFunc(func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	repos := []RepoSpec{{URL: "github.com/org/a"}, {URL: "github.com/org/b"}}
+	result, err := Backup(ctx, repos, WithDestination(t.TempDir()), WithEventSink(sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Interrupted || result.Count(OutcomeSkipped) != 2 || result.Status() != "interrupted" {
+		t.Errorf("result %+v, want an interrupted run with both repositories skipped", result)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !slices.ContainsFunc(events, func(event Event) bool { return event.Type == EventRegistered && event.Task == "logistics" }) {
+		t.Errorf("events %+v lack the logistics task", events)
+	}
+}
+
+func TestLoadRepoSpecs(t *testing.T) {
+	repos, err := LoadRepoSpecs("github.com/org/a")
+	if err != nil || len(repos) != 1 || repos[0].URL != "github.com/org/a" {
+		t.Errorf("LoadRepoSpecs() = %+v, %v; want the direct repository", repos, err)
+	}
+}
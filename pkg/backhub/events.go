@@ -0,0 +1,78 @@
+package backhub
+
+import "time"
+
+// Kind of progress event emitted during a backup
+type EventType string
+
+const (
+	EventRegistered EventType = "registered" // a task was created
+	EventMessage    EventType = "message"    // the task message changed
+	EventStatus     EventType = "status"     // the task status changed
+	EventStreamLine EventType = "stream"     // a detail line was added
+	EventProgress   EventType = "progress"   // the latest git progress lines
+	EventCompleted  EventType = "completed"  // the task finished successfully
+	EventError      EventType = "error"      // the task failed
+)
+
+// Progress event; Task is "logistics" for the run itself or "repo-<url>" per repository
+type Event struct {
+	Type    EventType
+	Task    string
+	Time    time.Time
+	Message string
+	Status  string
+	Line    string
+	Lines   []string
+	Err     error
+}
+
+// Receives progress events; implementations must be safe for concurrent use
+type EventSink interface {
+	HandleEvent(event Event)
+}
+
+// Adapts a function to the EventSink interface
+type EventSinkFunc func(event Event)
+
+func (f EventSinkFunc) HandleEvent(event Event) {
+	f(event)
+}
+
+// Translates the handler's task updates into events
+type eventAdapter struct {
+	sink EventSink
+}
+
+func (a *eventAdapter) emit(event Event) {
+	event.Time = time.Now()
+	a.sink.HandleEvent(event)
+}
+
+func (a *eventAdapter) Register(name string) {
+	a.emit(Event{Type: EventRegistered, Task: name})
+}
+
+func (a *eventAdapter) SetMessage(name, message string) {
+	a.emit(Event{Type: EventMessage, Task: name, Message: message})
+}
+
+func (a *eventAdapter) SetStatus(name, status string) {
+	a.emit(Event{Type: EventStatus, Task: name, Status: status})
+}
+
+func (a *eventAdapter) AddStreamLine(name, line string) {
+	a.emit(Event{Type: EventStreamLine, Task: name, Line: line})
+}
+
+func (a *eventAdapter) UpdateStreamOutput(name string, output []string) {
+	a.emit(Event{Type: EventProgress, Task: name, Lines: append([]string{}, output...)})
+}
+
+func (a *eventAdapter) Complete(name string) {
+	a.emit(Event{Type: EventCompleted, Task: name, Status: "success"})
+}
+
+func (a *eventAdapter) ReportError(name string, err error) {
+	a.emit(Event{Type: EventError, Task: name, Status: "error", Err: err})
+}
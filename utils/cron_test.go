@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	after := time.Date(2025, 3, 5, 10, 7, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 3, 5, 10, 15, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, 3, 5, 13, 0, 0, 0, time.UTC)},
+		{"5,45 10 * * *", time.Date(2025, 3, 5, 10, 45, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 3, 5, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Restricting both day fields matches either of them: the 13th or a Friday
+		{"0 0 13 * 5", time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 4", time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC)},
+		// A stepped star still counts as unrestricted, so both must match: the 21st is a Friday
+		{"0 0 */10 * 5", time.Date(2025, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"30 23 31 * *", time.Date(2025, 3, 31, 23, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("%q: %s", test.expr, err)
+			continue
+		}
+		if got := schedule.Next(after); !got.Equal(test.want) {
+			t.Errorf("%q: next after %s is %s, want %s", test.expr, after, got, test.want)
+		}
+	}
+}
+
+func TestCronPrev(t *testing.T) {
+	// Monday 08:00, a send time of the weekday schedule
+	before := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 8 * * 1-5", time.Date(2025, 3, 7, 8, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 3, 10, 7, 45, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("%q: %s", test.expr, err)
+			continue
+		}
+		if got := schedule.Prev(before); !got.Equal(test.want) {
+			t.Errorf("%q: previous before %s is %s, want %s", test.expr, before, got, test.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@often",
+		"0 0 31 2 *",
+		"0 0 30 2 *",
+		"0 0 31 4,6,9,11 *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q parsed without error", expr)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Registers a function that groups others, like an org whose repos are backed up;
+// it completes once size children have finished and collapses if all succeeded
+func (m *Manager) RegisterGroup(name string, size int) {
+	m.Register(name)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.outputs[name].GroupSize = size
+}
+
+// Registers a function nested under a group registered with RegisterGroup
+func (m *Manager) RegisterChild(name, parent string) {
+	m.Register(name)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	group, exists := m.outputs[parent]
+	if !exists {
+		return // shown at the top level instead
+	}
+	m.outputs[name].Parent = parent
+	group.Children = append(group.Children, name)
+}
+
+// Reports whether a function was registered as a group
+func isGroup(info *FunctionOutput) bool {
+	return info.GroupSize > 0
+}
+
+// Returns the children of a group in registration order; callers hold the lock
+func (m *Manager) groupChildren(info *FunctionOutput) []*FunctionOutput {
+	children := make([]*FunctionOutput, 0, len(info.Children))
+	for _, name := range info.Children {
+		children = append(children, m.outputs[name])
+	}
+	return children
+}
+
+// Counts the finished children of a group
+func (m *Manager) groupCounts(info *FunctionOutput) (succeeded, failed int) {
+	for _, child := range m.groupChildren(info) {
+		if child.Complete && child.Status == "error" {
+			failed++
+		} else if child.Complete {
+			succeeded++
+		}
+	}
+	return succeeded, failed
+}
+
+// Aggregates the status of a child's group after the child finished; callers hold the lock
+func (m *Manager) updateParent(info *FunctionOutput) {
+	if info.Parent == "" {
+		return
+	}
+	group := m.outputs[info.Parent]
+	succeeded, failed := m.groupCounts(group)
+	switch {
+	case succeeded+failed >= group.GroupSize && failed > 0:
+		group.Complete, group.Status = true, "error"
+	case succeeded+failed >= group.GroupSize:
+		group.Complete, group.Status = true, "success"
+	case failed > 0:
+		group.Status = "warning"
+	}
+	group.LastUpdated = m.now()
+	if group.Complete {
+		m.emit(Event{Type: EventCompleted, Function: group.Name, Status: group.Status})
+	}
+}
+
+// Completes a group whose remaining children will never run, like after an interrupt
+func (m *Manager) FinishGroup(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	group, exists := m.outputs[name]
+	if !exists || group.Complete {
+		return
+	}
+	group.Complete, group.Status = true, "warning"
+	if _, failed := m.groupCounts(group); failed > 0 {
+		group.Status = "error"
+	}
+	group.LastUpdated = m.now()
+	m.emit(Event{Type: EventCompleted, Function: name, Status: group.Status})
+}
+
+// Returns the counts shown after a group's message
+func (m *Manager) groupSuffix(info *FunctionOutput) string {
+	succeeded, failed := m.groupCounts(info)
+	suffix := fmt.Sprintf(" (%d/%d done", succeeded+failed, info.GroupSize)
+	if failed > 0 {
+		suffix += fmt.Sprintf(", %d failed", failed)
+	}
+	if skipped := info.GroupSize - succeeded - failed; info.Complete && skipped > 0 {
+		suffix += fmt.Sprintf(", %d skipped", skipped)
+	}
+	return debugStyle.Render(suffix + ")")
+}
+
+// Children shown below a group: running and failed ones, or all of them in
+// unlimited mode; a group whose children all succeeded collapses to one line
+func (m *Manager) visibleChildren(info *FunctionOutput) []*FunctionOutput {
+	var visible []*FunctionOutput
+	for _, child := range m.groupChildren(info) {
+		if m.unlimitedOutput || !child.Complete || child.Status == "error" {
+			visible = append(visible, child)
+		}
+	}
+	return visible
+}
+
+// Formats the lines of a group's visible children with the running ones' streams,
+// cut to streamLimit lines unless it returns -1
+func (m *Manager) formatChildLines(info *FunctionOutput, streamLimit func(*FunctionOutput) int) []string {
+	var lines []string
+	indent := strings.Repeat(" ", 4)
+	streamIndent := strings.Repeat(" ", basePadding+8)
+	for idx, child := range m.visibleChildren(info) {
+		if child.Complete {
+			lines = append(lines, indent+m.formatCompletedLine(idx+1, child))
+		} else {
+			lines = append(lines, indent+m.formatActiveLine(idx+1, child))
+		}
+		stream := child.StreamLines
+		if child.Complete && !m.unlimitedOutput {
+			stream = nil
+		}
+		if limit := streamLimit(child); limit >= 0 && len(stream) > limit {
+			stream = stream[len(stream)-limit:]
+		}
+		for _, line := range stream {
+			lines = append(lines, streamIndent+streamStyle.Render(line))
+		}
+	}
+	return lines
+}
+
+// Counts finished functions other than groups, whether nested or not
+func (m *Manager) leafCounts() (succeeded, failed int) {
+	for _, info := range m.outputs {
+		switch {
+		case isGroup(info) || !info.Complete:
+		case info.Status == "error":
+			failed++
+		default:
+			succeeded++
+		}
+	}
+	return succeeded, failed
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Keyboard control of the tty display, enabled with SetInteractive
+type interactive struct {
+	input    *os.File
+	keys     *os.File // Read for keypresses, closed when the display stops unless it is input
+	onQuit   func()
+	oldState *term.State // Terminal state restored when the display stops
+	selected string      // Function whose header is highlighted
+	verbose  map[string]bool
+}
+
+// Reads keypresses from input while the display runs: p pauses rendering, up/down
+// (or k/j) select a running function, d toggles its full stream output and q or
+// Ctrl+C call onQuit. Ignored unless input is a terminal.
+func (m *Manager) SetInteractive(input *os.File, onQuit func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !term.IsTerminal(int(input.Fd())) {
+		return
+	}
+	m.interactive = &interactive{input: input, onQuit: onQuit, verbose: make(map[string]bool)}
+}
+
+// Puts the terminal into raw mode and starts reading keys; called by StartDisplay
+func (m *Manager) startInteractive() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.interactive == nil {
+		return
+	}
+	oldState, err := term.MakeRaw(int(m.interactive.input.Fd()))
+	if err != nil {
+		m.interactive = nil
+		return
+	}
+	m.interactive.oldState = oldState
+	m.interactive.keys = openKeyInput(m.interactive.input)
+	go m.readKeys(m.interactive.keys)
+}
+
+// Restores the terminal and stops reading keys
+func (m *Manager) stopInteractive() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.interactive == nil || m.interactive.oldState == nil {
+		return
+	}
+	term.Restore(int(m.interactive.input.Fd()), m.interactive.oldState)
+	m.interactive.oldState = nil
+	if keys := m.interactive.keys; keys != nil && keys != m.interactive.input {
+		keys.Close() // unblocks readKeys
+	}
+}
+
+// Reads keys until the input fails or is closed by stopInteractive
+func (m *Manager) readKeys(input *os.File) {
+	buf := make([]byte, 16)
+	for {
+		n, err := input.Read(buf)
+		if err != nil {
+			return
+		}
+		for idx := 0; idx < n; idx++ {
+			key := buf[idx]
+			if key == 0x1b && idx+2 < n && buf[idx+1] == '[' { // arrow key escape sequence
+				switch buf[idx+2] {
+				case 'A':
+					key = 'k'
+				case 'B':
+					key = 'j'
+				}
+				idx += 2
+			}
+			if !m.handleKey(key) {
+				return
+			}
+		}
+	}
+}
+
+// Applies a key; returns false once the terminal has been restored
+func (m *Manager) handleKey(key byte) bool {
+	m.mutex.Lock()
+	if m.interactive == nil || m.interactive.oldState == nil {
+		m.mutex.Unlock()
+		return false
+	}
+	onQuit := m.interactive.onQuit
+	switch key {
+	case 'p', 'P':
+		m.paused.Store(!m.paused.Load())
+	case 'k', 'j':
+		m.moveSelection(key == 'j')
+	case 'd', 'D':
+		if selected := m.interactive.selected; selected != "" {
+			m.interactive.verbose[selected] = !m.interactive.verbose[selected]
+		}
+	case 'q', 'Q', 0x03: // raw mode turns Ctrl+C into a key instead of SIGINT
+		m.mutex.Unlock()
+		if onQuit != nil {
+			onQuit()
+		}
+		m.requestRedraw()
+		return true
+	}
+	m.mutex.Unlock()
+	m.requestRedraw()
+	return true
+}
+
+// Moves the selection to the next or previous running function; callers hold the lock
+func (m *Manager) moveSelection(down bool) {
+	active, _, _ := m.sortFunctions()
+	if len(active) == 0 {
+		m.interactive.selected = ""
+		return
+	}
+	current := -1
+	for idx, f := range active {
+		if f.name == m.interactive.selected {
+			current = idx
+		}
+	}
+	switch {
+	case current < 0:
+		current = 0
+	case down:
+		current = min(current+1, len(active)-1)
+	default:
+		current = max(current-1, 0)
+	}
+	m.interactive.selected = active[current].name
+}
+
+// Returns the footer describing the keys; callers hold the lock
+func (m *Manager) interactiveFooter() string {
+	state := "running"
+	if m.paused.Load() {
+		state = "paused"
+	}
+	return debugStyle.Render("  [" + state + "] " + StyleSymbols["arrow"] + " up/down select " + StyleSymbols["dot"] + " d details " + StyleSymbols["dot"] + " p pause " + StyleSymbols["dot"] + " q stop")
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package utils
+
+import "os"
+
+// Opens the terminal again for reading keys, as a file that Close unblocks; stdin
+// itself is blocking, so a read on it would outlive the display
+func openKeyInput(input *os.File) *os.File {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return input
+	}
+	return tty
+}
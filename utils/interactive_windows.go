@@ -0,0 +1,8 @@
+package utils
+
+import "os"
+
+// Console reads can't be interrupted, so keys are read from input directly
+func openKeyInput(input *os.File) *os.File {
+	return input
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity of a log file entry
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogDebug: "DEBUG",
+	LogInfo:  "INFO",
+	LogWarn:  "WARN",
+	LogError: "ERROR",
+}
+
+// Parses a level name like "debug" or "warn"
+func ParseLogLevel(level string) (LogLevel, error) {
+	for value, name := range logLevelNames {
+		if strings.EqualFold(level, name) {
+			return value, nil
+		}
+	}
+	if strings.EqualFold(level, "warning") {
+		return LogWarn, nil
+	}
+	return LogDebug, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", level)
+}
+
+// Number of rotated files kept next to the log file (path.1 is the newest)
+const logBackups = 3
+
+// Writes manager events with timestamps to a file, rotating it by size. Lines are
+// queued and written by a separate goroutine, so logging never waits on the disk
+type LogFile struct {
+	path     string
+	maxSize  int64 // Rotate once the file grows beyond this many bytes, 0 disables rotation
+	level    LogLevel
+	file     *os.File
+	size     int64
+	fallback io.Writer // Receives lines once the file can't be reopened after a rotation
+	closeErr error
+	queue    []string
+	closed   bool
+	mutex    sync.Mutex    // Guards queue and closed
+	wake     chan struct{} // Signals the writer that the queue has lines
+	done     chan struct{} // Closed once the writer has written everything
+}
+
+// Opens a log file for appending
+func OpenLogFile(path string, maxSize int64, level LogLevel) (*LogFile, error) {
+	l := &LogFile{
+		path:    path,
+		maxSize: maxSize,
+		level:   level,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	go l.writeLoop()
+	return l, nil
+}
+
+func (l *LogFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Shifts path -> path.1 -> path.2 ..., dropping the oldest, and starts a new file
+func (l *LogFile) rotate() error {
+	l.file.Close()
+	l.file = nil
+	for idx := logBackups - 1; idx > 0; idx-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, idx), fmt.Sprintf("%s.%d", l.path, idx+1))
+	}
+	os.Rename(l.path, l.path+".1")
+	return l.open()
+}
+
+// Writes queued lines until the log file is closed; only this goroutine touches the file
+func (l *LogFile) writeLoop() {
+	defer close(l.done)
+	for range l.wake {
+		l.mutex.Lock()
+		lines := l.queue
+		l.queue = nil
+		l.mutex.Unlock()
+		for _, line := range lines {
+			l.write(line)
+		}
+	}
+	// Lines queued between the last wake-up and Close
+	l.mutex.Lock()
+	lines := l.queue
+	l.mutex.Unlock()
+	for _, line := range lines {
+		l.write(line)
+	}
+	if l.file != nil {
+		l.closeErr = l.file.Close()
+	}
+}
+
+func (l *LogFile) write(line string) {
+	if l.fallback != nil {
+		io.WriteString(l.fallback, line)
+		return
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			l.fallback = os.Stderr
+			fmt.Fprintf(l.fallback, "Log file rotation failed, logging to stderr instead: %s\n", err)
+			io.WriteString(l.fallback, line)
+			return
+		}
+	}
+	n, _ := l.file.WriteString(line)
+	l.size += int64(n)
+}
+
+// Queues a line for a function if the level passes the filter
+func (l *LogFile) Log(level LogLevel, function, message string, t time.Time) {
+	if level < l.level {
+		return
+	}
+	line := fmt.Sprintf("%s %-5s [%s] %s\n", t.Format(time.RFC3339Nano), logLevelNames[level], function, message)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return
+	}
+	l.queue = append(l.queue, line)
+	select {
+	case l.wake <- struct{}{}:
+	default: // the writer has a wake-up pending already
+	}
+}
+
+// Writes an event of the output manager
+func (l *LogFile) LogEvent(event Event) {
+	switch event.Type {
+	case EventRegistered:
+		l.Log(LogInfo, event.Function, "registered", event.Time)
+	case EventMessage:
+		l.Log(LogInfo, event.Function, event.Message, event.Time)
+	case EventStatus:
+		level := LogInfo
+		if event.Status == "warning" {
+			level = LogWarn
+		} else if event.Status == "error" {
+			level = LogError
+		}
+		l.Log(level, event.Function, "status "+event.Status, event.Time)
+	case EventStreamLine:
+		l.Log(LogDebug, event.Function, event.Line, event.Time)
+	case EventProgress:
+		l.Log(LogDebug, event.Function, fmt.Sprintf("%.0f%% %s", event.Percent, event.Line), event.Time)
+	case EventCompleted:
+		level := LogInfo
+		if event.Status == "error" {
+			level = LogError
+		}
+		l.Log(level, event.Function, completionText(event), event.Time)
+	case EventError:
+		l.Log(LogError, event.Function, fmt.Sprintf("error (%s): %s", event.Category, event.Error), event.Time)
+	}
+}
+
+// Writes the remaining lines and closes the file; later lines are dropped
+func (l *LogFile) Close() error {
+	l.mutex.Lock()
+	if l.closed {
+		l.mutex.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.wake)
+	l.mutex.Unlock()
+	<-l.done
+	return l.closeErr
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backhub.log")
+	logFile, err := OpenLogFile(path, 100, LogInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for range 6 {
+		logFile.Log(LogInfo, "repo-a", "cloning github.com/org/a", now)
+	}
+	logFile.Log(LogDebug, "repo-a", "filtered by level", now)
+	if err := logFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+	logFile.Log(LogError, "repo-a", "after close", now)
+
+	var lines int
+	for _, name := range []string{path, path + ".1", path + ".2", path + ".3"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 100 {
+			t.Errorf("%s has %d bytes, want at most 100", name, len(data))
+		}
+		if strings.Contains(string(data), "filtered") || strings.Contains(string(data), "after close") {
+			t.Errorf("%s = %q, want only info lines logged before Close", name, data)
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 4 { // three backups kept, the oldest two lines were dropped
+		t.Errorf("found %d lines, want 4", lines)
+	}
+}
+
+func TestLogFileRotationFallsBackToStderr(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "backhub.log")
+	logFile, err := OpenLogFile(path, 100, LogInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	logFile.Log(LogInfo, "repo-a", "cloning github.com/org/a", now)
+
+	stderrReader, stderrWriter, _ := os.Pipe()
+	stderr := os.Stderr
+	os.Stderr = stderrWriter
+	os.RemoveAll(dir) // the file can't be reopened once rotated
+	for range 3 {
+		logFile.Log(LogInfo, "repo-a", "cloning github.com/org/a", now)
+	}
+	logFile.Close()
+	os.Stderr = stderr
+	stderrWriter.Close()
+
+	var errOut bytes.Buffer
+	errOut.ReadFrom(stderrReader)
+	if got := strings.Count(errOut.String(), "rotation failed"); got != 1 {
+		t.Errorf("stderr reported the failure %d times, want once: %q", got, errOut.String())
+	}
+	if got := strings.Count(errOut.String(), "[repo-a]"); got < 2 {
+		t.Errorf("stderr got %d log lines, want the lines after the failure: %q", got, errOut.String())
+	}
+}
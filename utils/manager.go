@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -26,17 +31,26 @@ var (
 
 	// Additional config
 	basePadding = 2
+	tableBorder = lipgloss.RoundedBorder()
 )
 
-var StyleSymbols = map[string]string{
-	"pass":    "✓",
-	"fail":    "✗",
-	"warning": "!",
-	"pending": "○",
-	"info":    "ℹ",
-	"arrow":   "→",
-	"bullet":  "•",
-	"dot":     "·",
+// Symbols used by the display, replaced by ConfigureDisplay for ASCII output
+var StyleSymbols = maps.Clone(unicodeSymbols)
+
+// Prints a standalone informational line outside of the managed display; like the
+// other standalone lines it goes to stderr, so it never mixes into plain or JSON output
+func PrintInfo(message string) {
+	fmt.Fprintln(os.Stderr, strings.Repeat(" ", basePadding)+infoStyle.Render(fmt.Sprintf("%s %s", StyleSymbols["info"], message)))
+}
+
+// Prints a standalone warning line outside of the managed display
+func PrintWarning(message string) {
+	fmt.Fprintln(os.Stderr, strings.Repeat(" ", basePadding)+warningStyle.Render(fmt.Sprintf("%s %s", StyleSymbols["warning"], message)))
+}
+
+// Prints a standalone error line outside of the managed display
+func PrintError(message string) {
+	fmt.Fprintln(os.Stderr, strings.Repeat(" ", basePadding)+errorStyle.Render(fmt.Sprintf("%s %s", StyleSymbols["fail"], message)))
 }
 
 // ======================================== =================
@@ -54,14 +68,12 @@ func NewTable(headers []string) *Table {
 		Headers: headers,
 		Rows:    [][]string{},
 	}
-	t.table = table.New().Headers(headers...)
+	t.table = table.New().Border(tableBorder).Headers(headers...)
 	return t
 }
 
 func (t *Table) ReconcileRows() {
-	if len(t.Rows) == 0 {
-		return
-	}
+	t.table.ClearRows() // rebuilt from Rows so formatting twice doesn't repeat them
 	for _, row := range t.Rows {
 		t.table.Row(row...)
 	}
@@ -70,7 +82,7 @@ func (t *Table) ReconcileRows() {
 func (t *Table) FormatTable(useMarkdown bool) string {
 	t.ReconcileRows()
 	if useMarkdown {
-		return t.table.Border(lipgloss.MarkdownBorder()).String()
+		return t.table.Border(lipgloss.MarkdownBorder()).BorderTop(false).BorderBottom(false).String()
 	}
 	return t.table.String()
 }
@@ -98,14 +110,28 @@ type FunctionOutput struct {
 	Error       error
 	Tables      map[string]*Table // Function tables
 	Index       int
+	BytesRecv   int64         // Bytes transferred so far, shown in the overall line
+	Expected    time.Duration // Expected duration from earlier runs, 0 if unknown
+	Parent      string        // Group the function is nested under, if any
+	Children    []string      // Functions nested under a group, in registration order
+	GroupSize   int           // Number of children a group waits for, 0 for other functions
 }
 
 type ErrorReport struct {
 	FunctionName string
 	Error        error
+	Category     string // "unknown" unless the error implements CategorizedError
+	Hint         string
 	Time         time.Time
 }
 
+// Implemented by errors that know their failure category, like functionality.BackupError
+type CategorizedError interface {
+	error
+	CategoryName() string
+	Hint() string
+}
+
 // Output manager main structure
 type Manager struct {
 	outputs         map[string]*FunctionOutput
@@ -116,28 +142,161 @@ type Manager struct {
 	tables          map[string]*Table // Global tables
 	errors          []ErrorReport
 	doneCh          chan struct{} // Channel to signal stopping the display
-	pauseCh         chan bool     // Channel to pause/resume display updates
-	isPaused        bool
+	paused          atomic.Bool   // Skips display updates while set, safe from any goroutine
+	redrawCh        chan struct{} // Requests an immediate redraw, even while paused
 	displayTick     time.Duration // Interval between display updates
 	functionCount   int
 	displayWg       sync.WaitGroup // WaitGroup for display goroutine shutdown
+	renderer        Renderer       // Draws the output (tty, plain or json)
+	overallName     string         // Function summarizing all others, "" when disabled
+	overallTotal    int            // Number of functions expected besides the overall one
+	overallStart    time.Time
+	logFile         *LogFile // Receives every event regardless of the renderer, may be nil
+	subscribers     map[int]chan Event
+	nextSubscriber  int
+	droppedEvents   int          // Events not delivered because a subscriber's buffer was full
+	out             io.Writer    // Destination of all display output
+	clock           Clock        // Source of timestamps and elapsed times
+	tickSource      TickSource   // Drives display updates
+	interactive     *interactive // Keyboard controls, nil unless enabled
+}
+
+// Source of the current time, replaceable for deterministic output
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Returns a channel delivering a tick every interval and a function stopping it
+type TickSource func(interval time.Duration) (<-chan time.Time, func())
+
+func systemTicks(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// Configures a Manager created by NewManager
+type ManagerOption func(*Manager)
+
+// Writes display output to w instead of stdout
+func WithWriter(w io.Writer) ManagerOption {
+	return func(m *Manager) {
+		m.out = w
+	}
+}
+
+// Takes timestamps and elapsed times from clock instead of the system time
+func WithClock(clock Clock) ManagerOption {
+	return func(m *Manager) {
+		m.clock = clock
+	}
 }
 
-func NewManager(maxStreams int) *Manager {
+// Drives display updates from ticks instead of a time.Ticker
+func WithTickSource(ticks TickSource) ManagerOption {
+	return func(m *Manager) {
+		m.tickSource = ticks
+	}
+}
+
+func NewManager(maxStreams int, opts ...ManagerOption) *Manager {
 	if maxStreams <= 0 {
 		maxStreams = 15 // Default
 	}
-	return &Manager{
+	m := &Manager{
 		outputs:         make(map[string]*FunctionOutput),
 		tables:          make(map[string]*Table),
 		errors:          []ErrorReport{},
 		maxStreams:      maxStreams,
 		unlimitedOutput: false,
 		doneCh:          make(chan struct{}),
-		pauseCh:         make(chan bool),
-		isPaused:        false,
+		redrawCh:        make(chan struct{}, 1),
 		displayTick:     200 * time.Millisecond, // Default
 		functionCount:   0,
+		renderer:        &ttyRenderer{},
+		subscribers:     make(map[int]chan Event),
+		out:             os.Stdout,
+		clock:           systemClock{},
+		tickSource:      systemTicks,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Manager) now() time.Time {
+	return m.clock.Now()
+}
+
+// Replaces the renderer; must be called before StartDisplay
+func (m *Manager) SetRenderer(renderer Renderer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.renderer = renderer
+}
+
+// Writes every event to a log file in addition to the renderer
+func (m *Manager) SetLogFile(logFile *LogFile) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.logFile = logFile
+}
+
+// Returns a channel receiving every event from now on, buffering up to the given
+// number of events; when the buffer is full further events are dropped so a slow
+// subscriber never blocks the callers. The channel is closed by the returned
+// function or when the display stops.
+func (m *Manager) Subscribe(buffer int) (<-chan Event, func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ch := make(chan Event, max(buffer, 1))
+	id := m.nextSubscriber
+	m.nextSubscriber++
+	m.subscribers[id] = ch
+	return ch, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if ch, exists := m.subscribers[id]; exists {
+			delete(m.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// Returns how many events were dropped because a subscriber fell behind
+func (m *Manager) DroppedEvents() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.droppedEvents
+}
+
+// Closes the channels of all subscribers
+func (m *Manager) closeSubscribers() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for id, ch := range m.subscribers {
+		delete(m.subscribers, id)
+		close(ch)
+	}
+}
+
+// Passes a change to the renderer, log file and subscribers; callers hold the write lock
+func (m *Manager) emit(event Event) {
+	event.Time = m.now()
+	if m.logFile != nil {
+		m.logFile.LogEvent(event)
+	}
+	m.renderer.HandleEvent(m, event)
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- event:
+		default:
+			m.droppedEvents++
+		}
 	}
 }
 
@@ -152,16 +311,22 @@ func (m *Manager) SetUpdateInterval(interval time.Duration) {
 }
 
 func (m *Manager) Pause() {
-	if !m.isPaused {
-		m.pauseCh <- true
-		m.isPaused = true
-	}
+	m.paused.Store(true)
 }
 
 func (m *Manager) Resume() {
-	if m.isPaused {
-		m.pauseCh <- false
-		m.isPaused = false
+	m.paused.Store(false)
+}
+
+func (m *Manager) IsPaused() bool {
+	return m.paused.Load()
+}
+
+// Asks the display goroutine to redraw on its next iteration
+func (m *Manager) requestRedraw() {
+	select {
+	case m.redrawCh <- struct{}{}:
+	default: // a redraw is already pending
 	}
 }
 
@@ -173,11 +338,12 @@ func (m *Manager) Register(name string) {
 		Name:        name,
 		Status:      "pending",
 		StreamLines: []string{},
-		StartTime:   time.Now(),
-		LastUpdated: time.Now(),
+		StartTime:   m.now(),
+		LastUpdated: m.now(),
 		Tables:      make(map[string]*Table),
 		Index:       m.functionCount,
 	}
+	m.emit(Event{Type: EventRegistered, Function: name})
 }
 
 func (m *Manager) SetMessage(name, message string) {
@@ -185,7 +351,8 @@ func (m *Manager) SetMessage(name, message string) {
 	defer m.mutex.Unlock()
 	if info, exists := m.outputs[name]; exists {
 		info.Message = message
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
+		m.emit(Event{Type: EventMessage, Function: name, Message: message})
 	}
 }
 
@@ -194,7 +361,8 @@ func (m *Manager) SetStatus(name, status string) {
 	defer m.mutex.Unlock()
 	if info, exists := m.outputs[name]; exists {
 		info.Status = status
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
+		m.emit(Event{Type: EventStatus, Function: name, Status: status})
 	}
 }
 
@@ -216,7 +384,9 @@ func (m *Manager) Complete(name string) {
 		}
 		info.Complete = true
 		info.Status = "success"
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
+		m.emit(Event{Type: EventCompleted, Function: name, Status: "success"})
+		m.updateParent(info)
 	}
 }
 
@@ -228,13 +398,22 @@ func (m *Manager) ReportError(name string, err error) {
 		info.Status = "error"
 		info.Message = fmt.Sprintf("Error: %v", err)
 		info.Error = err
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
 		// Add to global error list
-		m.errors = append(m.errors, ErrorReport{
+		report := ErrorReport{
 			FunctionName: name,
 			Error:        err,
-			Time:         time.Now(),
-		})
+			Category:     "unknown",
+			Time:         m.now(),
+		}
+		var categorized CategorizedError
+		if errors.As(err, &categorized) {
+			report.Category = categorized.CategoryName()
+			report.Hint = categorized.Hint()
+		}
+		m.errors = append(m.errors, report)
+		m.emit(Event{Type: EventError, Function: name, Status: "error", Error: err.Error(), Category: report.Category})
+		m.updateParent(info)
 	}
 }
 
@@ -260,7 +439,10 @@ func (m *Manager) UpdateStreamOutput(name string, output []string) {
 				info.StreamLines = append(info.StreamLines, output...)
 			}
 		}
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
+		if len(output) > 0 { // progress buffers repeat earlier lines, only the newest is an event
+			m.emit(Event{Type: EventStreamLine, Function: name, Line: output[len(output)-1]})
+		}
 	}
 }
 
@@ -278,7 +460,8 @@ func (m *Manager) AddStreamLine(name string, line string) {
 				info.StreamLines = append(info.StreamLines, line)
 			}
 		}
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
+		m.emit(Event{Type: EventStreamLine, Function: name, Line: line})
 	}
 }
 
@@ -290,10 +473,135 @@ func (m *Manager) AddProgressBarToStream(name string, percentage float64, text s
 		progressBar := PrintProgressBar(int(percentage), 100, 30)
 		display := progressBar + debugStyle.Render(text)
 		info.StreamLines = []string{display} // Set as only stream so nothing else is displayed
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
+		m.emit(Event{Type: EventProgress, Function: name, Line: text, Percent: percentage})
 	}
 }
 
+// Shows an overall progress line for the given function, counting the other
+// functions against the number expected in total
+func (m *Manager) TrackOverall(name string, total int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.overallName = name
+	m.overallTotal = total
+	m.overallStart = m.now()
+}
+
+// Records the bytes a function has transferred so far
+func (m *Manager) SetBytesReceived(name string, bytes int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if info, exists := m.outputs[name]; exists {
+		info.BytesRecv = bytes
+	}
+}
+
+// Records how long a function took in earlier runs, used for the overall ETA
+func (m *Manager) SetExpectedDuration(name string, expected time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if info, exists := m.outputs[name]; exists {
+		info.Expected = expected
+	}
+}
+
+// Snapshot of the functions a manager tracks, not counting groups or the overall function
+type Stats struct {
+	Running   int
+	Pending   int
+	Succeeded int
+	Failed    int
+	Queued    int // Expected by TrackOverall but not registered yet
+}
+
+// Returns a snapshot of the functions' states
+func (m *Manager) Stats() Stats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var stats Stats
+	registered := 0
+	for name, info := range m.outputs {
+		if name == m.overallName || isGroup(info) {
+			continue
+		}
+		registered++
+		switch {
+		case info.Complete && info.Status == "error":
+			stats.Failed++
+		case info.Complete:
+			stats.Succeeded++
+		case info.Status == "pending" && info.Message == "":
+			stats.Pending++
+		default:
+			stats.Running++
+		}
+	}
+	if m.overallName != "" {
+		stats.Queued = max(m.overallTotal-registered, 0)
+	}
+	return stats
+}
+
+// Formats the overall progress line; callers hold the lock
+func (m *Manager) formatOverallLine() string {
+	var done, running, registered int
+	var bytes int64
+	var remaining, expectedSum time.Duration
+	var expectedCount int
+	for name, info := range m.outputs {
+		if name == m.overallName || isGroup(info) {
+			continue
+		}
+		registered++
+		bytes += info.BytesRecv
+		if info.Expected > 0 {
+			expectedSum += info.Expected
+			expectedCount++
+		}
+		if info.Complete {
+			done++
+			continue
+		}
+		running++
+		if info.Expected > 0 {
+			remaining += max(info.Expected-m.now().Sub(info.StartTime), 0)
+		}
+	}
+	queued := max(m.overallTotal-registered, 0)
+	parts := []string{fmt.Sprintf("%d/%d done, %d running, %d queued", done, m.overallTotal, running, queued)}
+	elapsed := m.now().Sub(m.overallStart)
+	if bytes > 0 {
+		parts = append(parts, fmt.Sprintf("%s received", FormatBytes(float64(bytes))))
+		if elapsed > 0 {
+			parts = append(parts, fmt.Sprintf("%s/s", FormatBytes(float64(bytes)/elapsed.Seconds())))
+		}
+	}
+	// Queued functions are assumed to take as long as the average known one,
+	// with the work spread over as many functions as are running now
+	if expectedCount > 0 && done < m.overallTotal {
+		remaining += time.Duration(queued) * (expectedSum / time.Duration(expectedCount))
+		eta := remaining / time.Duration(max(running, 1))
+		parts = append(parts, fmt.Sprintf("ETA %s", eta.Round(time.Second)))
+	}
+	return fmt.Sprintf("%s%s %s", strings.Repeat(" ", basePadding), m.GetStatusIndicator("info"),
+		headerStyle.Render("Overall: ")+infoStyle.Render(strings.Join(parts, " "+StyleSymbols["dot"]+" ")))
+}
+
+// Formats a byte count with binary units as git does
+func FormatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+	return fmt.Sprintf("%.2f %s", bytes, units[unit])
+}
+
 func PrintProgressBar(current, total int, width int) string {
 	if width <= 0 {
 		width = 30
@@ -314,7 +622,7 @@ func (m *Manager) ClearLines(n int) {
 	if n <= 0 {
 		return
 	}
-	fmt.Printf("\033[%dA\033[J", n)
+	fmt.Fprintf(m.out, "\033[%dA\033[J", n)
 	m.numLines = max(m.numLines-n, 0)
 }
 
@@ -324,7 +632,7 @@ func (m *Manager) ClearFunction(name string) {
 	if info, exists := m.outputs[name]; exists {
 		info.StreamLines = []string{}
 		info.Message = ""
-		info.LastUpdated = time.Now()
+		info.LastUpdated = m.now()
 	}
 }
 
@@ -333,7 +641,7 @@ func (m *Manager) ClearAll() {
 	defer m.mutex.Unlock()
 	for name := range m.outputs {
 		m.outputs[name].StreamLines = []string{}
-		m.outputs[name].LastUpdated = time.Now()
+		m.outputs[name].LastUpdated = m.now()
 	}
 }
 
@@ -382,8 +690,11 @@ func (m *Manager) sortFunctions() (active, pending, completed []struct {
 		info  *FunctionOutput
 		index int
 	}
-	// Collect all functions
+	// Collect all functions; nested ones are shown by their group
 	for name, info := range m.outputs {
+		if info.Parent != "" {
+			continue
+		}
 		allFuncs = append(allFuncs, struct {
 			name  string
 			info  *FunctionOutput
@@ -416,48 +727,93 @@ func (m *Manager) sortFunctions() (active, pending, completed []struct {
 	return active, pending, completed
 }
 
+// Formats the header line of a running function with its elapsed time
+func (m *Manager) formatActiveLine(number int, info *FunctionOutput) string {
+	statusDisplay := m.GetStatusIndicator(info.Status)
+	elapsed := m.now().Sub(info.StartTime).Round(time.Millisecond)
+	elapsedStr := fmt.Sprintf("[%s]", elapsed)
+
+	// Style the message based on status
+	var styledMessage string
+	var prefixStyle lipgloss.Style
+	switch info.Status {
+	case "success":
+		styledMessage = successStyle.Render(info.Message)
+		prefixStyle = successStyle
+	case "error":
+		styledMessage = errorStyle.Render(info.Message)
+		prefixStyle = errorStyle
+	case "warning":
+		styledMessage = warningStyle.Render(info.Message)
+		prefixStyle = warningStyle
+	default: // pending or other
+		styledMessage = pendingStyle.Render(info.Message)
+		prefixStyle = pendingStyle
+	}
+	functionPrefix := strings.Repeat(" ", basePadding) + prefixStyle.Render(fmt.Sprintf("%d. ", number))
+	if isGroup(info) {
+		styledMessage += m.groupSuffix(info)
+	}
+	return fmt.Sprintf("%s%s %s %s", functionPrefix, statusDisplay, debugStyle.Render(elapsedStr), styledMessage)
+}
+
+// Formats the line of a completed function with its total time
+func (m *Manager) formatCompletedLine(number int, info *FunctionOutput) string {
+	statusDisplay := m.GetStatusIndicator(info.Status)
+	totalTime := info.LastUpdated.Sub(info.StartTime).Round(time.Millisecond)
+	timeStr := fmt.Sprintf("[%s]", totalTime)
+
+	// Style message based on status
+	var styledMessage string
+	var prefixStyle lipgloss.Style
+	if info.Status == "success" {
+		prefixStyle = successStyle
+		styledMessage = successStyle.Render(info.Message)
+	} else if info.Status == "error" {
+		prefixStyle = errorStyle
+		styledMessage = errorStyle.Render(info.Message)
+	} else if info.Status == "warning" {
+		prefixStyle = warningStyle
+		styledMessage = warningStyle.Render(info.Message)
+	} else { // pending or other
+		prefixStyle = pendingStyle
+		styledMessage = pendingStyle.Render(info.Message)
+	}
+	functionPrefix := strings.Repeat(" ", basePadding) + prefixStyle.Render(fmt.Sprintf("%d. ", number))
+	if isGroup(info) {
+		styledMessage += m.groupSuffix(info)
+	}
+	return fmt.Sprintf("%s%s %s %s", functionPrefix, statusDisplay, debugStyle.Render(timeStr), styledMessage)
+}
+
 func (m *Manager) updateDisplay() {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	if m.numLines > 0 && !m.unlimitedOutput {
-		fmt.Printf("\033[%dA\033[J", m.numLines)
+		fmt.Fprintf(m.out, "\033[%dA\033[J", m.numLines)
 	}
 	lineCount := 0
 	activeFuncs, pendingFuncs, completedFuncs := m.sortFunctions()
+	if m.overallName != "" {
+		fmt.Fprintln(m.out, m.formatOverallLine())
+		lineCount++
+	}
 
 	// Display active functions
 	for idx, f := range activeFuncs {
 		info := f.info
-		statusDisplay := m.GetStatusIndicator(info.Status)
-		elapsed := time.Since(info.StartTime).Round(time.Millisecond)
-		elapsedStr := fmt.Sprintf("[%s]", elapsed)
-
-		// Style the message based on status
-		var styledMessage string
-		var prefixStyle lipgloss.Style
-		switch info.Status {
-		case "success":
-			styledMessage = successStyle.Render(info.Message)
-			prefixStyle = successStyle
-		case "error":
-			styledMessage = errorStyle.Render(info.Message)
-			prefixStyle = errorStyle
-		case "warning":
-			styledMessage = warningStyle.Render(info.Message)
-			prefixStyle = warningStyle
-		default: // pending or other
-			styledMessage = pendingStyle.Render(info.Message)
-			prefixStyle = pendingStyle
-		}
-		functionPrefix := strings.Repeat(" ", basePadding) + prefixStyle.Render(fmt.Sprintf("%d. ", idx+1))
-		fmt.Printf("%s%s %s %s\n", functionPrefix, statusDisplay, debugStyle.Render(elapsedStr), styledMessage)
+		fmt.Fprintln(m.out, m.formatActiveLine(idx+1, info))
 		lineCount++
+		for _, line := range m.formatChildLines(info, func(*FunctionOutput) int { return -1 }) {
+			fmt.Fprintln(m.out, line)
+			lineCount++
+		}
 
 		// Print stream lines with indentation
 		if len(info.StreamLines) > 0 {
 			indent := strings.Repeat(" ", basePadding+4) // Additional indentation for stream output
 			for _, line := range info.StreamLines {
-				fmt.Printf("%s%s\n", indent, streamStyle.Render(line))
+				fmt.Fprintf(m.out, "%s%s\n", indent, streamStyle.Render(line))
 				lineCount++
 			}
 		}
@@ -468,12 +824,12 @@ func (m *Manager) updateDisplay() {
 		info := f.info
 		statusDisplay := m.GetStatusIndicator(info.Status)
 		functionPrefix := strings.Repeat(" ", basePadding) + pendingStyle.Render(fmt.Sprintf("%d. ", len(activeFuncs)+idx+1))
-		fmt.Printf("%s%s %s\n", functionPrefix, statusDisplay, pendingStyle.Render("Waiting..."))
+		fmt.Fprintf(m.out, "%s%s %s\n", functionPrefix, statusDisplay, pendingStyle.Render("Waiting..."))
 		lineCount++
 		if len(info.StreamLines) > 0 {
 			indent := strings.Repeat(" ", basePadding+4)
 			for _, line := range info.StreamLines {
-				fmt.Printf("%s%s\n", indent, streamStyle.Render(line))
+				fmt.Fprintf(m.out, "%s%s\n", indent, streamStyle.Render(line))
 				lineCount++
 			}
 		}
@@ -482,37 +838,18 @@ func (m *Manager) updateDisplay() {
 	// Display completed functions
 	for idx, f := range completedFuncs {
 		info := f.info
-		statusDisplay := m.GetStatusIndicator(info.Status)
-		totalTime := info.LastUpdated.Sub(info.StartTime).Round(time.Millisecond)
-		timeStr := fmt.Sprintf("[%s]", totalTime)
-		prefixStyle := successStyle
-		if info.Status == "error" {
-			prefixStyle = errorStyle
-		}
-
-		// Style message based on status
-		var styledMessage string
-		if info.Status == "success" {
-			styledMessage = successStyle.Render(info.Message)
-		} else if info.Status == "error" {
-			prefixStyle = errorStyle
-			styledMessage = errorStyle.Render(info.Message)
-		} else if info.Status == "warning" {
-			prefixStyle = warningStyle
-			styledMessage = warningStyle.Render(info.Message)
-		} else { // pending or other
-			prefixStyle = pendingStyle
-			styledMessage = pendingStyle.Render(info.Message)
-		}
-		functionPrefix := strings.Repeat(" ", basePadding) + prefixStyle.Render(fmt.Sprintf("%d. ", len(activeFuncs)+len(pendingFuncs)+idx+1))
-		fmt.Printf("%s%s %s %s\n", functionPrefix, statusDisplay, debugStyle.Render(timeStr), styledMessage)
+		fmt.Fprintln(m.out, m.formatCompletedLine(len(activeFuncs)+len(pendingFuncs)+idx+1, info))
 		lineCount++
+		for _, line := range m.formatChildLines(info, func(*FunctionOutput) int { return -1 }) {
+			fmt.Fprintln(m.out, line)
+			lineCount++
+		}
 
 		// Print stream lines with indentation if unlimited mode is enabled
 		if m.unlimitedOutput && len(info.StreamLines) > 0 {
 			indent := strings.Repeat(" ", basePadding+4)
 			for _, line := range info.StreamLines {
-				fmt.Printf("%s%s\n", indent, streamStyle.Render(line))
+				fmt.Fprintf(m.out, "%s%s\n", indent, streamStyle.Render(line))
 				lineCount++
 			}
 		}
@@ -521,26 +858,23 @@ func (m *Manager) updateDisplay() {
 }
 
 func (m *Manager) StartDisplay() {
+	m.startInteractive()
 	m.displayWg.Add(1)
 	go func() {
 		defer m.displayWg.Done()
-		ticker := time.NewTicker(m.displayTick)
-		defer ticker.Stop()
+		ticks, stop := m.tickSource(m.displayTick)
+		defer stop()
 		for {
 			select {
-			case <-ticker.C:
-				if !m.isPaused {
-					m.updateDisplay()
+			case <-ticks:
+				if !m.paused.Load() {
+					m.renderer.Tick(m)
 				}
-			case pauseState := <-m.pauseCh:
-				m.isPaused = pauseState
+			case <-m.redrawCh:
+				m.renderer.Tick(m)
 			case <-m.doneCh:
-				if !m.unlimitedOutput {
-					m.ClearAll()
-				}
-				m.updateDisplay()
-				m.ShowSummary()
-				m.displayTables()
+				m.stopInteractive() // restore the terminal before the summary
+				m.renderer.Finish(m)
 				return
 			}
 		}
@@ -550,16 +884,17 @@ func (m *Manager) StartDisplay() {
 func (m *Manager) StopDisplay() {
 	close(m.doneCh)
 	m.displayWg.Wait() // Wait for goroutine to finish
+	m.closeSubscribers()
 }
 
 func (m *Manager) displayTables() {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	if len(m.tables) > 0 {
-		fmt.Println(strings.Repeat(" ", basePadding) + headerStyle.Render("Global Tables:"))
-		for name, table := range m.tables {
-			fmt.Println(strings.Repeat(" ", basePadding+2) + headerStyle.Render(name))
-			fmt.Println(table.FormatTable(false))
+		fmt.Fprintln(m.out, strings.Repeat(" ", basePadding)+headerStyle.Render("Global Tables:"))
+		for _, name := range slices.Sorted(maps.Keys(m.tables)) {
+			fmt.Fprintln(m.out, strings.Repeat(" ", basePadding+2)+headerStyle.Render(name))
+			fmt.Fprintln(m.out, m.tables[name].FormatTable(false))
 		}
 	}
 	// Display function tables
@@ -571,13 +906,17 @@ func (m *Manager) displayTables() {
 		}
 	}
 	if hasFunctionTables {
-		fmt.Println(strings.Repeat(" ", basePadding) + headerStyle.Render("Function Tables:"))
-		for _, info := range m.outputs {
+		fmt.Fprintln(m.out, strings.Repeat(" ", basePadding)+headerStyle.Render("Function Tables:"))
+		// Registration order, so the output is stable
+		infos := slices.SortedFunc(maps.Values(m.outputs), func(a, b *FunctionOutput) int {
+			return a.Index - b.Index
+		})
+		for _, info := range infos {
 			if len(info.Tables) > 0 {
-				fmt.Println(strings.Repeat(" ", basePadding+2) + headerStyle.Render(info.Name))
-				for tableName, table := range info.Tables {
-					fmt.Println(strings.Repeat(" ", basePadding+4) + infoStyle.Render(tableName))
-					fmt.Println(table.FormatTable(false))
+				fmt.Fprintln(m.out, strings.Repeat(" ", basePadding+2)+headerStyle.Render(info.Name))
+				for _, tableName := range slices.Sorted(maps.Keys(info.Tables)) {
+					fmt.Fprintln(m.out, strings.Repeat(" ", basePadding+4)+infoStyle.Render(tableName))
+					fmt.Fprintln(m.out, info.Tables[tableName].FormatTable(false))
 				}
 			}
 		}
@@ -588,36 +927,80 @@ func (m *Manager) displayErrors() {
 	if len(m.errors) == 0 {
 		return
 	}
-	fmt.Println()
-	fmt.Println(strings.Repeat(" ", basePadding) + errorStyle.Bold(true).Render("Errors:"))
+	fmt.Fprintln(m.out)
+	fmt.Fprintln(m.out, strings.Repeat(" ", basePadding)+errorStyle.Bold(true).Render("Errors:"))
 	for i, err := range m.errors {
-		fmt.Printf("%s%s %s %s\n",
+		fmt.Fprintf(m.out, "%s%s %s %s\n",
 			strings.Repeat(" ", basePadding+2),
 			errorStyle.Render(fmt.Sprintf("%d.", i+1)),
 			debugStyle.Render(fmt.Sprintf("[%s]", err.Time.Format("15:04:05"))),
 			errorStyle.Render(fmt.Sprintf("Function: %s", err.FunctionName)))
-		fmt.Printf("%s%s\n", strings.Repeat(" ", basePadding+4), errorStyle.Render(fmt.Sprintf("Error: %v", err.Error)))
+		fmt.Fprintf(m.out, "%s%s\n", strings.Repeat(" ", basePadding+4), errorStyle.Render(fmt.Sprintf("Error: %v", err.Error)))
+	}
+}
+
+// Groups of error reports sharing a category, largest group first
+func (m *Manager) errorCategories() [][]ErrorReport {
+	groups := make(map[string][]ErrorReport)
+	for _, report := range m.errors {
+		groups[report.Category] = append(groups[report.Category], report)
+	}
+	var sorted [][]ErrorReport
+	for _, group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i][0].Category < sorted[j][0].Category
+	})
+	return sorted
+}
+
+// Lists failures grouped by category, each with its remediation hint
+func (m *Manager) displayErrorCategories() {
+	if len(m.errors) == 0 {
+		return
+	}
+	fmt.Fprintln(m.out, strings.Repeat(" ", basePadding)+errorStyle.Bold(true).Render("Failures by category:"))
+	for _, group := range m.errorCategories() {
+		var names []string
+		for _, report := range group {
+			names = append(names, report.FunctionName)
+		}
+		fmt.Fprintf(m.out, "%s%s %s\n", strings.Repeat(" ", basePadding+2),
+			errorStyle.Render(fmt.Sprintf("%s (%d)", group[0].Category, len(group))),
+			debugStyle.Render(strings.Join(names, ", ")))
+		if group[0].Hint != "" {
+			fmt.Fprintf(m.out, "%s%s %s\n", strings.Repeat(" ", basePadding+4), warningStyle.Render(StyleSymbols["arrow"]), warningStyle.Render(group[0].Hint))
+		}
 	}
 }
 
 func (m *Manager) ShowSummary() {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	fmt.Println()
-	var success, failures int
+	fmt.Fprintln(m.out)
+	var success, failures, total int
 	for _, info := range m.outputs {
+		if isGroup(info) { // counted through their children
+			continue
+		}
+		total++
 		if info.Status == "success" {
 			success++
 		} else if info.Status == "error" {
 			failures++
 		}
 	}
-	totalOps := fmt.Sprintf("Total Operations: %d", len(m.outputs))
+	totalOps := fmt.Sprintf("Total Operations: %d", total)
 	succeeded := fmt.Sprintf("Succeeded: %s", successStyle.Render(fmt.Sprintf("%d", success)))
 	failed := fmt.Sprintf("Failed: %s", errorStyle.Render(fmt.Sprintf("%d", failures)))
-	fmt.Println(infoStyle.Padding(0, basePadding).Render(fmt.Sprintf("%s, %s, %s", totalOps, succeeded, failed)))
+	fmt.Fprintln(m.out, infoStyle.Padding(0, basePadding).Render(fmt.Sprintf("%s, %s, %s", totalOps, succeeded, failed)))
+	m.displayErrorCategories()
 	if m.unlimitedOutput {
 		m.displayErrors()
 	}
-	fmt.Println()
+	fmt.Fprintln(m.out)
 }
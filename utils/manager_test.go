@@ -0,0 +1,532 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	lipgloss.SetColorProfile(termenv.Ascii) // no colors, whatever the test's stdout is
+	os.Exit(m.Run())
+}
+
+// Clock that only moves when told to
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Renderer wrapper that reports when a tick has been drawn
+type syncRenderer struct {
+	Renderer
+	drawn chan struct{}
+}
+
+func (r *syncRenderer) Tick(m *Manager) {
+	r.Renderer.Tick(m)
+	r.drawn <- struct{}{}
+}
+
+// Manager writing to a buffer with a fake clock and manual ticks
+type testManager struct {
+	*Manager
+	out    *bytes.Buffer
+	clock  *fakeClock
+	ticks  chan time.Time
+	render *syncRenderer
+}
+
+func newTestManager(t *testing.T, mode string) *testManager {
+	t.Helper()
+	tm := &testManager{out: &bytes.Buffer{}, clock: newFakeClock(), ticks: make(chan time.Time)}
+	tm.Manager = NewManager(5,
+		WithWriter(tm.out),
+		WithClock(tm.clock),
+		WithTickSource(func(time.Duration) (<-chan time.Time, func()) { return tm.ticks, func() {} }),
+	)
+	renderer, err := NewRenderer(mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm.render = &syncRenderer{Renderer: renderer, drawn: make(chan struct{})}
+	tm.SetRenderer(tm.render)
+	return tm
+}
+
+// Draws one frame and waits until it is written
+func (tm *testManager) tick() {
+	tm.ticks <- tm.clock.Now()
+	<-tm.render.drawn
+}
+
+// Compares output with testdata/<name>.golden, rewriting it with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept it)\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// Error that carries a category, like functionality.BackupError
+type categorizedError struct {
+	category, hint string
+}
+
+func (e categorizedError) Error() string        { return "failed with " + e.category }
+func (e categorizedError) CategoryName() string { return e.category }
+func (e categorizedError) Hint() string         { return e.hint }
+
+// Runs the same sequence of updates against a manager, ticking between steps
+func runScenario(tm *testManager, ticking bool) {
+	tick := func() {
+		if ticking {
+			tm.tick()
+		}
+	}
+	tm.StartDisplay()
+	tm.Register("coordinator")
+	tm.SetMessage("coordinator", "Processing 3 repositories")
+	tm.TrackOverall("coordinator", 3)
+	tm.AddStreamLine("coordinator", "Loaded 3 repositories")
+	tm.Register("repo-small")
+	tm.SetMessage("repo-small", "Cloning github.com/user/small")
+	tm.Register("repo-large")
+	tm.SetMessage("repo-large", "Cloning github.com/user/large")
+	tm.SetExpectedDuration("repo-large", 10*time.Second)
+	tm.AddProgressBarToStream("repo-large", 40, "Compressing objects 400/1000")
+	tm.SetBytesReceived("repo-large", 3<<20)
+	tm.clock.Advance(1500 * time.Millisecond)
+	tick()
+
+	tm.AddStreamLine("repo-small", "Clone completed successfully")
+	tm.SetMessage("repo-small", "Successfully cloned github.com/user/small")
+	tm.Complete("repo-small")
+	tm.Register("repo-private")
+	tm.SetMessage("repo-private", "Cloning github.com/org/private")
+	tm.SetStatus("repo-private", "warning")
+	tm.AddStreamLine("repo-private", "Retrying clone in 2s (attempt 2/3)")
+	tm.clock.Advance(2 * time.Second)
+	tick()
+
+	tm.ReportError("repo-private", categorizedError{"auth", "token lacks repo scope"})
+	tm.AddProgressBarToStream("repo-large", 100, "Compressing objects 1000/1000")
+	tm.Complete("repo-large")
+	tm.SetMessage("coordinator", "Backup process completed")
+	tm.Complete("coordinator")
+	tm.clock.Advance(500 * time.Millisecond)
+	tick()
+
+	results := tm.RegisterTable("Backup Results", []string{"Repository", "Action"})
+	results.Rows = append(results.Rows, []string{"github.com/user/small", "cloned"}, []string{"github.com/org/private", "failed"})
+	details := tm.RegisterFunctionTable("repo-large", "Transfer", []string{"Phase", "Objects"})
+	details.Rows = append(details.Rows, []string{"Compressing", "1000"})
+	tm.StopDisplay()
+}
+
+func TestTTYRenderer(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	runScenario(tm, true)
+	assertGolden(t, "tty", tm.out.Bytes())
+}
+
+func TestTTYRendererDebug(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	tm.SetUnlimitedOutput(true)
+	runScenario(tm, true)
+	assertGolden(t, "tty_debug", tm.out.Bytes())
+}
+
+func TestPlainRenderer(t *testing.T) {
+	tm := newTestManager(t, OutputPlain)
+	runScenario(tm, false)
+	assertGolden(t, "plain", tm.out.Bytes())
+}
+
+func TestJSONRenderer(t *testing.T) {
+	tm := newTestManager(t, OutputJSON)
+	runScenario(tm, false)
+	assertGolden(t, "json", tm.out.Bytes())
+}
+
+func TestTTYViewport(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	tm.StartDisplay()
+	for idx := range 20 {
+		name := fmt.Sprintf("task-%d", idx)
+		tm.Register(name)
+		tm.SetMessage(name, "working on "+name)
+		for line := range 5 {
+			tm.AddStreamLine(name, fmt.Sprintf("%s line %d", name, line))
+		}
+	}
+	frame := (&ttyRenderer{}).buildFrame(tm.Manager, 40, 12)
+	if len(frame) != 12 {
+		t.Fatalf("frame has %d lines, want 12", len(frame))
+	}
+	if !strings.Contains(frame[11], "9 more running") {
+		t.Errorf("last line = %q, want the hidden task counter", frame[11])
+	}
+	for _, line := range frame {
+		if width := ansi.StringWidth(line); width > 40 {
+			t.Errorf("line %q is %d columns wide, want at most 40", line, width)
+		}
+	}
+	tm.StopDisplay()
+}
+
+func TestTTYViewportCounters(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	for idx := range 10 {
+		name := fmt.Sprintf("task-%d", idx)
+		tm.Register(name)
+		switch {
+		case idx < 3: // pending, no message yet
+		case idx < 6:
+			tm.SetMessage(name, "done with "+name)
+			tm.Complete(name)
+		case idx == 6:
+			tm.ReportError(name, errors.New("boom"))
+		default:
+			tm.SetMessage(name, "working on "+name)
+			tm.AddStreamLine(name, name+" progress")
+		}
+	}
+	frame := strings.Join((&ttyRenderer{}).buildFrame(tm.Manager, 60, 12), "\n")
+	for _, want := range []string{"working on task-9", "3 pending", "4 completed (3 succeeded, 1 failed)", "Error: boom"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame lacks %q:\n%s", want, frame)
+		}
+	}
+	for height := range 5 {
+		if frame := (&ttyRenderer{}).buildFrame(tm.Manager, 60, height); len(frame) > height {
+			t.Errorf("frame for %d rows has %d lines", height, len(frame))
+		}
+	}
+}
+
+func TestSubscribeDropsWhenFull(t *testing.T) {
+	tm := newTestManager(t, OutputPlain)
+	events, unsubscribe := tm.Subscribe(2)
+	tm.Register("a")
+	tm.SetMessage("a", "one")
+	tm.SetMessage("a", "two")
+	if got := len(events); got != 2 {
+		t.Fatalf("buffered %d events, want 2", got)
+	}
+	if got := tm.DroppedEvents(); got != 1 {
+		t.Errorf("dropped %d events, want 1", got)
+	}
+	if event := <-events; event.Type != EventRegistered || event.Function != "a" {
+		t.Errorf("first event = %+v, want registered a", event)
+	}
+	unsubscribe()
+	unsubscribe() // closing twice is harmless
+	<-events
+	if _, open := <-events; open {
+		t.Error("channel still open after unsubscribe")
+	}
+}
+
+func TestSubscribeEventStream(t *testing.T) {
+	tm := newTestManager(t, OutputPlain)
+	tm.StartDisplay()
+	first, _ := tm.Subscribe(10)
+	second, _ := tm.Subscribe(10)
+	tm.Register("a")
+	tm.SetMessage("a", "cloning")
+	tm.AddStreamLine("a", "Counting objects: 3/3 done")
+	tm.AddProgressBarToStream("a", 40, "Receiving objects 4/10")
+	tm.Complete("a")
+	tm.StopDisplay() // closes every subscription
+
+	want := []EventType{EventRegistered, EventMessage, EventStreamLine, EventProgress, EventCompleted}
+	for _, events := range []<-chan Event{first, second} {
+		var got []EventType
+		for event := range events {
+			if event.Function != "a" || !event.Time.Equal(tm.clock.Now()) {
+				t.Errorf("event %+v, want function a at the manager's clock", event)
+			}
+			if event.Type == EventProgress && (event.Percent != 40 || event.Line != "Receiving objects 4/10") {
+				t.Errorf("progress event %+v, want the bar's percentage and text", event)
+			}
+			got = append(got, event.Type)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("event types %v, want %v", got, want)
+		}
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	tm := newTestManager(t, OutputPlain)
+	for _, name := range []string{"a", "b", "c"} {
+		tm.Register(name)
+	}
+	tm.ReportError("a", categorizedError{"network", "check connectivity"})
+	tm.ReportError("b", errors.New("plain failure"))
+	tm.ReportError("c", categorizedError{"network", "check connectivity"})
+	groups := tm.errorCategories()
+	if len(groups) != 2 || groups[0][0].Category != "network" || len(groups[0]) != 2 || groups[1][0].Category != "unknown" {
+		t.Errorf("unexpected groups %+v", groups)
+	}
+}
+
+func TestSummaryListsCategories(t *testing.T) {
+	tm := newTestManager(t, OutputPlain)
+	for _, name := range []string{"a", "b", "c"} {
+		tm.Register(name)
+	}
+	tm.ReportError("a", fmt.Errorf("clone: %w", categorizedError{"auth", "check GH_TOKEN"}))
+	tm.ReportError("b", categorizedError{"auth", "check GH_TOKEN"})
+	tm.ReportError("c", errors.New("plain failure"))
+	tm.ShowSummary()
+	out := tm.out.String()
+	for _, want := range []string{"Failed: 3", "Failures by category:", "auth (2) a, b", "check GH_TOKEN", "unknown (1) c"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPauseResumeConcurrent(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	tm.StartDisplay()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				tm.Pause()
+				tm.Resume()
+			}
+		}()
+	}
+	wg.Wait()
+	tm.Pause()
+	if !tm.IsPaused() {
+		t.Error("manager not paused after Pause")
+	}
+	tm.StopDisplay() // must not block on a paused display
+}
+
+func TestInteractiveKeys(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	tm.interactive = &interactive{oldState: &term.State{}, verbose: make(map[string]bool)}
+	for _, name := range []string{"a", "b"} {
+		tm.Register(name)
+		tm.SetMessage(name, "working on "+name)
+	}
+	for _, key := range []byte("jjd") {
+		tm.handleKey(key)
+	}
+	if tm.interactive.selected != "b" || !tm.interactive.verbose["b"] {
+		t.Errorf("selected %q with verbose %v, want b toggled to verbose", tm.interactive.selected, tm.interactive.verbose)
+	}
+	tm.handleKey('k')
+	tm.handleKey('p')
+	if tm.interactive.selected != "a" || !tm.IsPaused() {
+		t.Errorf("selected %q, paused %v; want a and paused", tm.interactive.selected, tm.IsPaused())
+	}
+}
+
+func TestStopInteractiveEndsKeyReader(t *testing.T) {
+	input, _, _ := os.Pipe() // stands in for the terminal, which is never restored in a test
+	keys, keysWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keysWriter.Close()
+	tm := newTestManager(t, OutputTTY)
+	tm.interactive = &interactive{input: input, keys: keys, oldState: &term.State{}, verbose: make(map[string]bool)}
+	done := make(chan struct{})
+	go func() {
+		tm.readKeys(keys)
+		close(done)
+	}()
+	keysWriter.Write([]byte("p"))
+	tm.stopInteractive()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("readKeys still blocked after stopInteractive")
+	}
+}
+
+func TestConfigureDisplayASCII(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	if err := ConfigureDisplay("neon", false); err == nil {
+		t.Error("unknown theme accepted")
+	}
+	if err := ConfigureDisplay(ThemeMonochrome, true); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureDisplay(ThemeDefault, false)
+	m := NewManager(5)
+	for _, status := range []string{"success", "error", "warning", "pending", "other"} {
+		if indicator := m.GetStatusIndicator(status); ansi.StringWidth(indicator) != len(ansi.Strip(indicator)) {
+			t.Errorf("indicator for %s is not ASCII: %q", status, indicator)
+		}
+	}
+	if bar := ansi.Strip(PrintProgressBar(50, 100, 10)); bar != "(*****>    ) 50.0% - " {
+		t.Errorf("progress bar = %q", bar)
+	}
+	table := NewTable([]string{"a"})
+	table.Rows = append(table.Rows, []string{"b"})
+	if formatted := table.FormatTable(false); strings.ContainsAny(formatted, "╭─│") {
+		t.Errorf("table uses Unicode borders:\n%s", formatted)
+	}
+}
+
+func TestGroupCollapse(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	for _, group := range []string{"group-ok", "group-bad"} {
+		tm.RegisterGroup(group, 2)
+		tm.SetMessage(group, group)
+	}
+	for _, child := range []string{"ok-1", "ok-2"} {
+		tm.RegisterChild(child, "group-ok")
+		tm.SetMessage(child, "working on "+child)
+	}
+	tm.RegisterChild("bad-1", "group-bad")
+	tm.SetMessage("bad-1", "working on bad-1")
+	tm.Complete("ok-1")
+	tm.ReportError("bad-1", errors.New("boom"))
+	if status := tm.GetStatus("group-bad"); status != "warning" {
+		t.Errorf("group with a failed child has status %q, want warning", status)
+	}
+	frame := strings.Join((&ttyRenderer{}).buildFrame(tm.Manager, 120, 40), "\n")
+	for _, want := range []string{"group-ok (1/2 done)", "working on ok-2", "group-bad (1/2 done, 1 failed)", "Error: boom", "2 completed (1 succeeded, 1 failed)"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame lacks %q:\n%s", want, frame)
+		}
+	}
+
+	tm.Complete("ok-2")
+	tm.RegisterChild("bad-2", "group-bad")
+	tm.Complete("bad-2")
+	if tm.GetStatus("group-ok") != "success" || tm.GetStatus("group-bad") != "error" {
+		t.Errorf("group statuses %q and %q, want success and error", tm.GetStatus("group-ok"), tm.GetStatus("group-bad"))
+	}
+	frame = strings.Join((&ttyRenderer{}).buildFrame(tm.Manager, 120, 40), "\n")
+	if strings.Contains(frame, "ok-1") || strings.Contains(frame, "bad-2") {
+		t.Errorf("succeeded children not collapsed:\n%s", frame)
+	}
+	if !strings.Contains(frame, "Error: boom") || !strings.Contains(frame, "4 completed (3 succeeded, 1 failed)") {
+		t.Errorf("failed child not expanded or wrong counts:\n%s", frame)
+	}
+}
+
+func TestFinishGroup(t *testing.T) {
+	tm := newTestManager(t, OutputTTY)
+	for _, group := range []string{"group-ok", "group-bad"} {
+		tm.RegisterGroup(group, 3)
+		tm.SetMessage(group, group)
+	}
+	tm.RegisterChild("ok-1", "group-ok")
+	tm.Complete("ok-1")
+	tm.RegisterChild("bad-1", "group-bad")
+	tm.ReportError("bad-1", errors.New("boom"))
+	tm.FinishGroup("group-ok")
+	tm.FinishGroup("group-bad")
+	if tm.GetStatus("group-ok") != "warning" || tm.GetStatus("group-bad") != "error" {
+		t.Errorf("group statuses %q and %q, want warning and error", tm.GetStatus("group-ok"), tm.GetStatus("group-bad"))
+	}
+	frame := strings.Join((&ttyRenderer{}).buildFrame(tm.Manager, 120, 40), "\n")
+	for _, want := range []string{"group-ok (1/3 done, 2 skipped)", "group-bad (1/3 done, 1 failed, 2 skipped)"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame lacks %q:\n%s", want, frame)
+		}
+	}
+	if strings.Contains(frame, "running") {
+		t.Errorf("finished groups still shown as running:\n%s", frame)
+	}
+}
+
+func TestGroupCompletionLines(t *testing.T) {
+	tm := newTestManager(t, OutputPlain)
+	for _, group := range []string{"group-ok", "group-bad"} {
+		tm.RegisterGroup(group, 1)
+	}
+	tm.RegisterChild("ok-1", "group-ok")
+	tm.Complete("ok-1")
+	tm.RegisterChild("bad-1", "group-bad")
+	tm.ReportError("bad-1", errors.New("boom"))
+	out := tm.out.String()
+	for _, want := range []string{"[group-ok] completed", "[group-bad] failed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "[group-bad] completed") {
+		t.Errorf("failed group logged as completed:\n%s", out)
+	}
+}
+
+func TestStandaloneLinesUseStderr(t *testing.T) {
+	stdoutReader, stdoutWriter, _ := os.Pipe()
+	stderrReader, stderrWriter, _ := os.Pipe()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdoutWriter, stderrWriter
+	PrintInfo("next run scheduled")
+	PrintWarning("ignoring history")
+	PrintError("failed to write report")
+	os.Stdout, os.Stderr = stdout, stderr
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
+	var out, errOut bytes.Buffer
+	out.ReadFrom(stdoutReader)
+	errOut.ReadFrom(stderrReader)
+	if out.Len() != 0 {
+		t.Errorf("stdout got %q, which would corrupt JSON output", out.String())
+	}
+	if strings.Count(errOut.String(), "\n") != 3 {
+		t.Errorf("stderr got %q, want three lines", errOut.String())
+	}
+}
@@ -0,0 +1,369 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/term"
+)
+
+// Output modes accepted by NewRenderer
+const (
+	OutputTTY   = "tty"
+	OutputPlain = "plain"
+	OutputJSON  = "json"
+)
+
+// Kind of change made to a function's output
+type EventType string
+
+const (
+	EventRegistered EventType = "registered"
+	EventMessage    EventType = "message"
+	EventStatus     EventType = "status"
+	EventStreamLine EventType = "stream"
+	EventProgress   EventType = "progress"
+	EventCompleted  EventType = "completed"
+	EventError      EventType = "error"
+)
+
+// Change made to a function's output, emitted in order by the manager
+type Event struct {
+	Type     EventType `json:"type"`
+	Function string    `json:"function"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Line     string    `json:"line,omitempty"`
+	Percent  float64   `json:"percent,omitempty"` // of progress events, whose Line holds the bar text
+	Error    string    `json:"error,omitempty"`
+	Category string    `json:"category,omitempty"`
+}
+
+// Draws the manager's output; HandleEvent is called with the manager lock held,
+// so it must not call back into locking Manager methods
+type Renderer interface {
+	HandleEvent(m *Manager, event Event) // every change as it happens
+	Tick(m *Manager)                     // every display interval
+	Finish(m *Manager)                   // once when the display stops
+}
+
+// Picks the renderer for a mode; an empty mode selects tty on terminals and plain otherwise
+func NewRenderer(mode string) (Renderer, error) {
+	if mode == "" {
+		mode = DetectOutputMode()
+	}
+	switch mode {
+	case OutputTTY:
+		return &ttyRenderer{}, nil
+	case OutputPlain:
+		return &plainRenderer{}, nil
+	case OutputJSON:
+		return &jsonRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown output mode %q (use tty, plain or json)", mode)
+}
+
+// Returns tty when stdout is a terminal that supports cursor movement and plain otherwise
+func DetectOutputMode() string {
+	if term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("TERM") != "dumb" {
+		return OutputTTY
+	}
+	return OutputPlain
+}
+
+// =========================================== ============
+// =========================================== TTY Renderer
+// =========================================== ============
+
+// Number of recently completed functions shown below the counters
+const recentCompletions = 5
+
+// Redraws a terminal-sized viewport in place: active functions in full, pending
+// and completed ones as counters plus a tail of recent completions
+type ttyRenderer struct {
+	prevFrame []string
+}
+
+func (r *ttyRenderer) HandleEvent(m *Manager, event Event) {}
+
+func (r *ttyRenderer) Tick(m *Manager) {
+	if m.unlimitedOutput { // debug mode prints everything without a viewport
+		m.updateDisplay()
+		return
+	}
+	width, height := terminalSize(m.out)
+	frame := r.buildFrame(m, width, height-1) // keep a spare row so the frame never scrolls
+	r.draw(m.out, frame)
+	m.mutex.Lock()
+	m.numLines = len(frame)
+	m.mutex.Unlock()
+}
+
+func (r *ttyRenderer) Finish(m *Manager) {
+	if !m.unlimitedOutput {
+		m.ClearAll()
+	}
+	m.updateDisplay()
+	m.ShowSummary()
+	m.displayTables()
+}
+
+// Returns the size of the terminal behind w, with a conservative default when it is unknown
+func terminalSize(w io.Writer) (int, int) {
+	file, ok := w.(*os.File)
+	if !ok {
+		return 120, 40
+	}
+	width, height, err := term.GetSize(int(file.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 120, 40
+	}
+	return width, height
+}
+
+// Lays out the lines that fit into the given height
+func (r *ttyRenderer) buildFrame(m *Manager, width, height int) []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	activeFuncs, pendingFuncs, completedFuncs := m.sortFunctions()
+	var overall, footer []string
+	if m.overallName != "" { // pinned to the top
+		overall = append(overall, m.formatOverallLine())
+		height--
+	}
+	if m.interactive != nil { // key help pinned to the bottom
+		footer = append(footer, m.interactiveFooter())
+		height--
+	}
+	success, failures := m.leafCounts()
+	counterLines := 0
+	if len(pendingFuncs) > 0 {
+		counterLines++
+	}
+	if success+failures > 0 {
+		counterLines++
+	}
+
+	// Active headers come first; if they don't all fit, the rest become a counter
+	shownActive := activeFuncs
+	hiddenActive := 0
+	if rows := max(height-counterLines, 1); len(activeFuncs) > rows {
+		shownActive = activeFuncs[:rows-1]
+		hiddenActive = len(activeFuncs) - len(shownActive)
+	}
+
+	// Share the rows left after headers and counters between the active stream lines,
+	// after functions toggled to verbose have taken all of theirs; running children
+	// of groups share them too
+	streamBudget := height - len(shownActive) - counterLines
+	streamLines, sharing := 0, 0
+	for _, f := range shownActive {
+		streaming := []*FunctionOutput{f.info}
+		if isGroup(f.info) {
+			children := m.visibleChildren(f.info)
+			streamBudget -= len(children)
+			streaming = slices.DeleteFunc(children, func(child *FunctionOutput) bool { return child.Complete })
+		}
+		for _, info := range streaming {
+			if m.isVerbose(info.Name) {
+				streamBudget -= len(info.StreamLines)
+				continue
+			}
+			streamLines += len(info.StreamLines)
+			sharing++
+		}
+	}
+	perFunction := -1 // no limit
+	if streamLines > streamBudget && sharing > 0 {
+		perFunction = max(streamBudget/sharing, 0)
+	}
+
+	var frame []string
+	indent := strings.Repeat(" ", basePadding+4)
+	for idx, f := range shownActive {
+		header := m.formatActiveLine(idx+1, f.info)
+		if m.interactive != nil && f.name == m.interactive.selected {
+			header = StyleSymbols["arrow"] + header[1:] // padding starts with a space
+		}
+		frame = append(frame, header)
+		lines := f.info.StreamLines
+		if perFunction >= 0 && len(lines) > perFunction && !m.isVerbose(f.name) {
+			lines = lines[len(lines)-perFunction:]
+		}
+		for _, line := range lines {
+			frame = append(frame, indent+streamStyle.Render(line))
+		}
+		frame = append(frame, m.formatChildLines(f.info, func(child *FunctionOutput) int {
+			if m.isVerbose(child.Name) {
+				return -1
+			}
+			return perFunction
+		})...)
+	}
+	if hiddenActive > 0 {
+		frame = append(frame, fmt.Sprintf("%s%s %s", strings.Repeat(" ", basePadding),
+			m.GetStatusIndicator("info"), pendingStyle.Render(fmt.Sprintf("%d more running", hiddenActive))))
+	}
+	if len(pendingFuncs) > 0 {
+		frame = append(frame, fmt.Sprintf("%s%s %s", strings.Repeat(" ", basePadding),
+			m.GetStatusIndicator("pending"), pendingStyle.Render(fmt.Sprintf("%d pending", len(pendingFuncs)))))
+	}
+	if success+failures > 0 {
+		frame = append(frame, fmt.Sprintf("%s%s %s", strings.Repeat(" ", basePadding),
+			m.GetStatusIndicator("success"), successStyle.Render(fmt.Sprintf("%d completed (%d succeeded, %d failed)", success+failures, success, failures))))
+		// Most recent completions, oldest first, as far as rows remain
+		recent := slices.Clone(completedFuncs)
+		slices.SortStableFunc(recent, func(a, b struct {
+			name string
+			info *FunctionOutput
+		}) int {
+			return a.info.LastUpdated.Compare(b.info.LastUpdated)
+		})
+		tail := min(recentCompletions, len(recent), max(height-len(frame), 0))
+		for idx, f := range recent[len(recent)-tail:] {
+			frame = append(frame, strings.Repeat(" ", 2)+m.formatCompletedLine(len(recent)-tail+idx+1, f.info))
+			for _, line := range m.formatChildLines(f.info, func(*FunctionOutput) int { return 0 }) {
+				frame = append(frame, strings.Repeat(" ", 2)+line) // failed children stay expanded
+			}
+		}
+	}
+	if len(frame) > height { // terminal smaller than the counters
+		frame = frame[:max(height, 0)]
+	}
+	frame = append(overall, frame...)
+	frame = append(frame, footer...)
+	for idx, line := range frame {
+		frame[idx] = ansi.Truncate(line, width, "")
+	}
+	return frame
+}
+
+// Reports whether a function was toggled to show its full stream output
+func (m *Manager) isVerbose(name string) bool {
+	return m.interactive != nil && m.interactive.verbose[name]
+}
+
+// Moves to the top of the previous frame and rewrites only the lines that changed
+func (r *ttyRenderer) draw(w io.Writer, frame []string) {
+	var out strings.Builder
+	if len(r.prevFrame) > 0 {
+		fmt.Fprintf(&out, "\r\033[%dA", len(r.prevFrame))
+	}
+	for idx, line := range frame {
+		if idx < len(r.prevFrame) && r.prevFrame[idx] == line {
+			out.WriteString("\n") // unchanged, just move down
+			continue
+		}
+		out.WriteString("\r\033[2K" + line + "\n")
+	}
+	out.WriteString("\033[J") // drop leftovers of a longer previous frame
+	fmt.Fprint(w, out.String())
+	r.prevFrame = frame
+}
+
+// =========================================== ==============
+// =========================================== Plain Renderer
+// =========================================== ==============
+
+// Prints one timestamped line per change, suitable for log files and CI
+type plainRenderer struct{}
+
+// Describes how a function finished; groups complete with an error status once a child failed
+func completionText(event Event) string {
+	if event.Status == "error" {
+		return "failed"
+	}
+	return "completed"
+}
+
+func (r *plainRenderer) HandleEvent(m *Manager, event Event) {
+	var text string
+	switch event.Type {
+	case EventMessage:
+		text = event.Message
+	case EventStatus:
+		text = fmt.Sprintf("status %s", event.Status)
+	case EventStreamLine:
+		text = "  " + event.Line
+	case EventProgress:
+		text = fmt.Sprintf("  %.0f%% %s", event.Percent, event.Line)
+	case EventCompleted:
+		text = completionText(event)
+	case EventError:
+		text = fmt.Sprintf("error: %s", event.Error)
+	default:
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	fmt.Fprintf(m.out, "%s [%s] %s\n", event.Time.Format(time.TimeOnly), event.Function, text)
+}
+
+func (r *plainRenderer) Tick(m *Manager) {}
+
+func (r *plainRenderer) Finish(m *Manager) {
+	m.ShowSummary()
+	m.displayTables()
+}
+
+// =========================================== =============
+// =========================================== JSON Renderer
+// =========================================== =============
+
+// Prints every change as a JSON object per line, followed by a summary object
+type jsonRenderer struct{}
+
+func (r *jsonRenderer) HandleEvent(m *Manager, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(m.out, string(data))
+}
+
+func (r *jsonRenderer) Tick(m *Manager) {}
+
+func (r *jsonRenderer) Finish(m *Manager) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	summary := struct {
+		Type      string         `json:"type"`
+		Time      time.Time      `json:"time"`
+		Total     int            `json:"total"`
+		Succeeded int            `json:"succeeded"`
+		Failed    int            `json:"failed"`
+		Errors    []string       `json:"errors,omitempty"`
+		Category  map[string]int `json:"failures_by_category,omitempty"`
+	}{Type: "summary", Time: m.now()}
+	for _, info := range m.outputs {
+		if isGroup(info) { // counted through their children
+			continue
+		}
+		summary.Total++
+		if info.Status == "success" {
+			summary.Succeeded++
+		} else if info.Status == "error" {
+			summary.Failed++
+		}
+	}
+	for _, report := range m.errors {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("%s: %v", report.FunctionName, report.Error))
+		if summary.Category == nil {
+			summary.Category = make(map[string]int)
+		}
+		summary.Category[report.Category]++
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(m.out, string(data))
+}
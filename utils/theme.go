@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme names accepted by ConfigureDisplay
+const (
+	ThemeDefault      = "default"
+	ThemeHighContrast = "high-contrast"
+	ThemeMonochrome   = "monochrome"
+)
+
+// Colors of a theme as ANSI codes; an empty color leaves the terminal default
+type theme struct {
+	success, error, warning, pending, info, debug, detail, stream, header string
+}
+
+var themes = map[string]theme{
+	ThemeDefault: {
+		success: "2", error: "9", warning: "11", pending: "12", info: "14",
+		debug: "250", detail: "13", stream: "240", header: "69",
+	},
+	ThemeHighContrast: {
+		success: "10", error: "9", warning: "11", pending: "14", info: "15",
+		debug: "15", detail: "15", stream: "252", header: "15",
+	},
+	ThemeMonochrome: {}, // statuses are told apart by their symbols
+}
+
+var unicodeSymbols = map[string]string{
+	"pass":    "✓",
+	"fail":    "✗",
+	"warning": "!",
+	"pending": "○",
+	"info":    "ℹ",
+	"arrow":   "→",
+	"bullet":  "•",
+	"dot":     "·",
+}
+
+var asciiSymbols = map[string]string{
+	"pass":    "+",
+	"fail":    "x",
+	"warning": "!",
+	"pending": "o",
+	"info":    "i",
+	"arrow":   ">",
+	"bullet":  "*",
+	"dot":     "-",
+}
+
+// Selects the theme and symbol set of the display. NO_COLOR forces the monochrome
+// theme and TERM=dumb additionally forces ASCII symbols.
+func ConfigureDisplay(themeName string, ascii bool) error {
+	if themeName == "" {
+		themeName = ThemeDefault
+	}
+	if _, exists := themes[themeName]; !exists {
+		return fmt.Errorf("unknown theme %q (use default, high-contrast or monochrome)", themeName)
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		themeName = ThemeMonochrome
+	}
+	if os.Getenv("TERM") == "dumb" {
+		ascii = true
+	}
+	applyTheme(themes[themeName], themeName == ThemeHighContrast)
+	applySymbols(ascii)
+	return nil
+}
+
+// Replaces the package styles with the colors of a theme
+func applyTheme(t theme, bold bool) {
+	style := func(color string) lipgloss.Style {
+		s := lipgloss.NewStyle().Bold(bold)
+		if color != "" {
+			s = s.Foreground(lipgloss.Color(color))
+		}
+		return s
+	}
+	successStyle = style(t.success)
+	errorStyle = style(t.error)
+	warningStyle = style(t.warning)
+	pendingStyle = style(t.pending)
+	infoStyle = style(t.info)
+	debugStyle = style(t.debug).Bold(false)
+	detailStyle = style(t.detail)
+	streamStyle = style(t.stream).Bold(false)
+	headerStyle = style(t.header).Bold(true)
+}
+
+// Switches StyleSymbols and table borders between Unicode and ASCII
+func applySymbols(ascii bool) {
+	symbols := unicodeSymbols
+	tableBorder = lipgloss.RoundedBorder()
+	if ascii {
+		symbols = asciiSymbols
+		tableBorder = lipgloss.ASCIIBorder()
+	}
+	StyleSymbols = make(map[string]string, len(symbols))
+	for key, symbol := range symbols {
+		StyleSymbols[key] = symbol
+	}
+}